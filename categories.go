@@ -18,9 +18,12 @@ import (
 )
 
 type Category struct {
-	Id    string `yaml:"id" json:"id"`
-	Title string `yaml:"title" json:"title"`
-	Count int    `yaml:"count" json:"count"`
+	Id          string `yaml:"id" json:"id"`
+	Title       string `yaml:"title" json:"title"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+	Icon        string `yaml:"icon,omitempty" json:"icon,omitempty"`
+	ParentId    string `yaml:"parent_id,omitempty" json:"parent_id,omitempty"`
+	Count       int    `yaml:"count" json:"count"`
 }
 
 // categoriesHandler is a dynamic handler as it will also allow filtering in the future.
@@ -94,19 +97,21 @@ func categoriesHandler(packagesBasePaths []string, cacheTime time.Duration) func
 			packageList[p.Name] = p
 		}
 
-		categories := map[string]*Category{}
+		// Packages and policy templates are collected by category and added to the parent categories too,
+		// so packages in several subcategories of a category are counted only once
+		categoryItems := map[string]map[string]bool{}
+		addItem := func(category, item string) {
+			for _, c := range append([]string{category}, util.CategoryAncestors(category)...) {
+				if _, ok := categoryItems[c]; !ok {
+					categoryItems[c] = map[string]bool{}
+				}
+				categoryItems[c][item] = true
+			}
+		}
 
 		for _, p := range packageList {
 			for _, c := range p.Categories {
-				if _, ok := categories[c]; !ok {
-					categories[c] = &Category{
-						Id:    c,
-						Title: c,
-						Count: 0,
-					}
-				}
-
-				categories[c].Count = categories[c].Count + 1
+				addItem(c, p.Name)
 			}
 
 			if includePolicyTemplates {
@@ -116,26 +121,28 @@ func categoriesHandler(packagesBasePaths []string, cacheTime time.Duration) func
 						break
 					}
 
+					item := p.Name + "/" + t.Name
 					for _, c := range p.Categories {
-						categories[c].Count = categories[c].Count + 1
+						addItem(c, item)
 					}
 
 					// Add policy template level categories.
 					for _, c := range t.Categories {
-						if _, ok := categories[c]; !ok {
-							categories[c] = &Category{
-								Id:    c,
-								Title: c,
-								Count: 0,
-							}
-						}
-
-						categories[c].Count = categories[c].Count + 1
+						addItem(c, item)
 					}
 				}
 			}
 		}
 
+		categories := map[string]*Category{}
+		for c, items := range categoryItems {
+			categories[c] = &Category{
+				Id:    c,
+				Title: c,
+				Count: len(items),
+			}
+		}
+
 		data, err := getCategoriesOutput(categories)
 		if err != nil {
 			notFoundError(w, err)
@@ -158,8 +165,11 @@ func getCategoriesOutput(categories map[string]*Category) ([]byte, error) {
 	var outputCategories []*Category
 	for _, k := range keys {
 		c := categories[k]
-		if title, ok := util.CategoryTitles[c.Title]; ok {
-			c.Title = title
+		if category, ok := util.GetCategory(c.Id); ok {
+			c.Title = category.Title
+			c.Description = category.Description
+			c.Icon = category.Icon
+			c.ParentId = category.Parent
 		}
 		outputCategories = append(outputCategories, c)
 	}
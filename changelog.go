@@ -0,0 +1,94 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"github.com/elastic/package-registry/util"
+)
+
+const (
+	changelogRouterPath = "/package/{packageName:[a-z0-9_]+}/changelog"
+)
+
+// changelogHandler aggregates the changelog entries of a package between the `from` version (exclusive)
+// and the `to` version (inclusive).
+func changelogHandler(packagesBasePaths []string, cacheTime time.Duration) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		packageName, ok := vars["packageName"]
+		if !ok {
+			badRequest(w, "missing package name")
+			return
+		}
+
+		query := r.URL.Query()
+
+		var from, to *semver.Version
+		var err error
+
+		// Read query filter params which can affect the output
+		if len(query) > 0 {
+			if v := query.Get("from"); v != "" {
+				from, err = semver.StrictNewVersion(v)
+				if err != nil {
+					badRequest(w, fmt.Sprintf("invalid 'from' version '%s': %s", v, err))
+					return
+				}
+			}
+
+			if v := query.Get("to"); v != "" {
+				to, err = semver.StrictNewVersion(v)
+				if err != nil {
+					badRequest(w, fmt.Sprintf("invalid 'to' version '%s': %s", v, err))
+					return
+				}
+			}
+		}
+
+		if from != nil && to != nil && from.GreaterThan(to) {
+			badRequest(w, fmt.Sprintf("'from' version %s is greater than 'to' version %s", from, to))
+			return
+		}
+
+		packages, err := util.GetPackages(packagesBasePaths)
+		if err != nil {
+			notFoundError(w, errors.Wrapf(err, "fetching package failed"))
+			return
+		}
+
+		if packages.Newest(packageName, nil) == nil {
+			notFoundError(w, errPackageNotFound)
+			return
+		}
+
+		data, err := getChangelogOutput(packages.Changelog(packageName, from, to))
+		if err != nil {
+			notFoundError(w, err)
+			return
+		}
+
+		cacheHeaders(w, cacheTime)
+		jsonHeader(w)
+		fmt.Fprint(w, string(data))
+	}
+}
+
+func getChangelogOutput(releases []util.ChangelogRelease) ([]byte, error) {
+	// Instead of return `null` in case of an empty array, return []
+	if len(releases) == 0 {
+		return []byte("[]"), nil
+	}
+
+	return json.MarshalIndent(releases, "", "  ")
+}
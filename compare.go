@@ -0,0 +1,75 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/elastic/package-registry/util"
+)
+
+const (
+	compareRouterPath = "/package/{packageName:[a-z0-9_]+}/compare"
+)
+
+// compareHandler reports the structural differences between the `from` and `to` versions of a package.
+func compareHandler(packagesBasePaths []string, cacheTime time.Duration) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		packageName, ok := vars["packageName"]
+		if !ok {
+			badRequest(w, "missing package name")
+			return
+		}
+
+		query := r.URL.Query()
+		fromVersion := query.Get("from")
+		if fromVersion == "" {
+			badRequest(w, "missing 'from' query param")
+			return
+		}
+
+		toVersion := query.Get("to")
+		if toVersion == "" {
+			badRequest(w, "missing 'to' query param")
+			return
+		}
+
+		from, ok := loadPackageVersion(w, packagesBasePaths, packageName, fromVersion)
+		if !ok {
+			return
+		}
+
+		to, ok := loadPackageVersion(w, packagesBasePaths, packageName, toVersion)
+		if !ok {
+			return
+		}
+
+		comparison, err := util.ComparePackages(from, to)
+		if err != nil {
+			log.Printf("comparing package versions failed (package: %s, from: %s, to: %s): %v", packageName, fromVersion, toVersion, err)
+
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		body, err := json.MarshalIndent(comparison, "", "  ")
+		if err != nil {
+			log.Printf("marshaling package comparison failed (package: %s): %v", packageName, err)
+
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		cacheHeaders(w, cacheTime)
+		jsonHeader(w)
+		w.Write(body)
+	}
+}
@@ -0,0 +1,300 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"github.com/elastic/package-registry/util"
+)
+
+const (
+	dataStreamRouterPath              = "/package/{packageName:[a-z0-9_]+}/{packageVersion}/data_stream/{dataStream}"
+	dataStreamFieldsRouterPath        = dataStreamRouterPath + "/fields"
+	dataStreamIndexTemplateRouterPath = dataStreamRouterPath + "/index_template"
+
+	dataStreamIngestPipelinesRouterPath = dataStreamRouterPath + "/ingest_pipelines"
+	dataStreamIngestPipelineRouterPath  = dataStreamIngestPipelinesRouterPath + "/{pipeline}"
+
+	dataStreamRenderRouterPath = dataStreamRouterPath + "/render"
+)
+
+var (
+	errDataStreamNotFound     = errors.New("data stream not found")
+	errIngestPipelineNotFound = errors.New("ingest pipeline not found")
+	errStreamNotFound         = errors.New("stream not found")
+)
+
+// dataStreamFieldsHandler returns the flattened list of fields of a data stream, in JSON or CSV format.
+func dataStreamFieldsHandler(packagesBasePaths []string, cacheTime time.Duration) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format := r.URL.Query().Get("format")
+		if format != "" && format != "json" && format != "csv" {
+			badRequest(w, fmt.Sprintf("invalid 'format' query param: '%s'", format))
+			return
+		}
+
+		_, d, ok := loadDataStream(w, r, packagesBasePaths)
+		if !ok {
+			return
+		}
+
+		fields, err := d.LoadFields()
+		if err != nil {
+			log.Printf("loading fields failed (path: %s): %v", d.BasePath, err)
+
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		var body []byte
+		if format == "csv" {
+			body, err = getFieldsCSVOutput(fields)
+		} else {
+			body, err = getFieldsJSONOutput(fields)
+		}
+		if err != nil {
+			log.Printf("marshaling fields failed (path: %s): %v", d.BasePath, err)
+
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		cacheHeaders(w, cacheTime)
+		if format == "csv" {
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		} else {
+			jsonHeader(w)
+		}
+		w.Write(body)
+	}
+}
+
+// dataStreamIndexTemplateHandler returns the composable index template generated for a data stream.
+func dataStreamIndexTemplateHandler(packagesBasePaths []string, cacheTime time.Duration) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, d, ok := loadDataStream(w, r, packagesBasePaths)
+		if !ok {
+			return
+		}
+
+		indexTemplate, err := d.IndexTemplate(p)
+		if err != nil {
+			log.Printf("generating index template failed (path: %s): %v", d.BasePath, err)
+
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		body, err := json.MarshalIndent(indexTemplate, "", "  ")
+		if err != nil {
+			log.Printf("marshaling index template failed (path: %s): %v", d.BasePath, err)
+
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		cacheHeaders(w, cacheTime)
+		jsonHeader(w)
+		w.Write(body)
+	}
+}
+
+// dataStreamIngestPipelinesHandler returns all the ingest pipelines of a data stream in JSON format,
+// independently of the format they are defined in.
+func dataStreamIngestPipelinesHandler(packagesBasePaths []string, cacheTime time.Duration) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, d, ok := loadDataStream(w, r, packagesBasePaths)
+		if !ok {
+			return
+		}
+
+		pipelines, err := d.LoadIngestPipelines(p.Version)
+		if err != nil {
+			log.Printf("loading ingest pipelines failed (path: %s): %v", d.BasePath, err)
+
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		// Instead of return `null` in case of an empty array, return []
+		body := []byte("[]")
+		if len(pipelines) > 0 {
+			body, err = json.MarshalIndent(pipelines, "", "  ")
+			if err != nil {
+				log.Printf("marshaling ingest pipelines failed (path: %s): %v", d.BasePath, err)
+
+				http.Error(w, "internal server error", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		cacheHeaders(w, cacheTime)
+		jsonHeader(w)
+		w.Write(body)
+	}
+}
+
+// dataStreamIngestPipelineHandler returns the body of a single ingest pipeline of a data stream in JSON format,
+// ready to be installed under its ID.
+func dataStreamIngestPipelineHandler(packagesBasePaths []string, cacheTime time.Duration) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, d, ok := loadDataStream(w, r, packagesBasePaths)
+		if !ok {
+			return
+		}
+
+		pipelines, err := d.LoadIngestPipelines(p.Version)
+		if err != nil {
+			log.Printf("loading ingest pipelines failed (path: %s): %v", d.BasePath, err)
+
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		pipeline := util.GetIngestPipeline(pipelines, mux.Vars(r)["pipeline"])
+		if pipeline == nil {
+			notFoundError(w, errIngestPipelineNotFound)
+			return
+		}
+
+		body, err := json.MarshalIndent(pipeline.Pipeline, "", "  ")
+		if err != nil {
+			log.Printf("marshaling ingest pipeline failed (path: %s): %v", d.BasePath, err)
+
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		cacheHeaders(w, cacheTime)
+		jsonHeader(w)
+		w.Write(body)
+	}
+}
+
+// dataStreamRenderHandler renders the agent stream template of a data stream with the variable values
+// given as JSON object in the request body. Defaults are applied for variables without value.
+func dataStreamRenderHandler(packagesBasePaths []string) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, d, ok := loadDataStream(w, r, packagesBasePaths)
+		if !ok {
+			return
+		}
+
+		var stream *util.Stream
+		input := r.URL.Query().Get("input")
+		if input != "" {
+			stream = d.GetStream(input)
+		} else if len(d.Streams) == 1 {
+			stream = &d.Streams[0]
+		} else if len(d.Streams) > 1 {
+			badRequest(w, "data stream has multiple streams, 'input' query param is required")
+			return
+		}
+		if stream == nil {
+			notFoundError(w, errStreamNotFound)
+			return
+		}
+
+		values := map[string]interface{}{}
+		if r.Body != nil {
+			err := json.NewDecoder(r.Body).Decode(&values)
+			if err != nil && err != io.EOF {
+				badRequest(w, fmt.Sprintf("invalid variables in request body: %v", err))
+				return
+			}
+		}
+
+		vars, err := p.ResolveStreamVariables(d, stream, values)
+		if err != nil {
+			badRequest(w, err.Error())
+			return
+		}
+
+		rendered, err := d.RenderStreamTemplate(stream, vars)
+		var templateErr *util.TemplateError
+		if errors.As(err, &templateErr) {
+			badRequest(w, templateErr.Error())
+			return
+		}
+		if err != nil {
+			log.Printf("rendering stream template failed (path: %s): %v", d.BasePath, err)
+
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		noCacheHeaders(w)
+		w.Header().Set("Content-Type", "text/yaml; charset=utf-8")
+		w.Write(rendered)
+	}
+}
+
+func getFieldsJSONOutput(fields []util.Field) ([]byte, error) {
+	// Instead of return `null` in case of an empty array, return []
+	if len(fields) == 0 {
+		return []byte("[]"), nil
+	}
+
+	return json.MarshalIndent(fields, "", "  ")
+}
+
+func getFieldsCSVOutput(fields []util.Field) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+
+	err := writer.Write([]string{"name", "type", "description", "multi_fields", "file", "external"})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range fields {
+		var multiFields []string
+		for _, m := range f.MultiFields {
+			multiFields = append(multiFields, m.Name+":"+m.Type)
+		}
+
+		err = writer.Write([]string{f.Name, f.Type, f.Description, strings.Join(multiFields, ","), f.File, f.External})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	writer.Flush()
+	return buf.Bytes(), writer.Error()
+}
+
+// loadDataStream loads the package and data stream given in the request path. If this is not possible,
+// the error is written to the response and false is returned.
+func loadDataStream(w http.ResponseWriter, r *http.Request, packagesBasePaths []string) (*util.Package, *util.DataStream, bool) {
+	dataStreamPath, ok := mux.Vars(r)["dataStream"]
+	if !ok {
+		badRequest(w, "missing data stream")
+		return nil, nil, false
+	}
+
+	p, ok := loadPackageFromRequest(w, r, packagesBasePaths)
+	if !ok {
+		return nil, nil, false
+	}
+
+	d := p.GetDataStream(dataStreamPath)
+	if d == nil {
+		notFoundError(w, errDataStreamNotFound)
+		return nil, nil, false
+	}
+	return p, d, true
+}
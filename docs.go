@@ -0,0 +1,158 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/gorilla/mux"
+
+	"github.com/elastic/package-registry/util"
+)
+
+const (
+	docsRouterPath = "/package/{packageName:[a-z0-9_]+}/{packageVersion}/docs/{doc:[^/]+\\.md}"
+
+	markdownContentType = "text/markdown; charset=utf-8"
+)
+
+// docsHandler returns the Markdown docs of a package. Docs without placeholders for fields are served as static
+// files, docs with placeholders are served with the placeholders replaced. Docs are rendered to HTML if requested
+// with `format=html` or an Accept header preferring HTML over Markdown.
+func docsHandler(packagesBasePaths []string, cacheTime time.Duration, contentDisposition string) func(w http.ResponseWriter, r *http.Request) {
+	static := staticHandler(packagesBasePaths, "/package", cacheTime, contentDisposition)
+	return func(w http.ResponseWriter, r *http.Request) {
+		// The format of the response depends on the Accept header, also for static files
+		w.Header().Add("Vary", "Accept")
+
+		format := r.URL.Query().Get("format")
+		if format != "" && format != "html" && format != "markdown" {
+			badRequest(w, fmt.Sprintf("invalid 'format' query param: '%s'", format))
+			return
+		}
+		html := format == "html" || (format == "" && prefersHTML(r.Header.Get("Accept")))
+
+		if !html {
+			placeholders, err := docHasPlaceholders(packagesBasePaths, mux.Vars(r))
+			if err == errResourceNotFound {
+				notFoundError(w, err)
+				return
+			}
+			if err != nil {
+				log.Printf("reading docs failed: %v", err)
+
+				http.Error(w, "internal server error", http.StatusInternalServerError)
+				return
+			}
+			if !placeholders {
+				w.Header().Set("Content-Type", markdownContentType)
+				static(w, r)
+				return
+			}
+		}
+
+		p, ok := loadPackageFromRequest(w, r, packagesBasePaths)
+		if !ok {
+			return
+		}
+
+		var body []byte
+		var err error
+		if html {
+			body, err = p.RenderDoc(mux.Vars(r)["doc"])
+		} else {
+			body, err = p.LoadDoc(mux.Vars(r)["doc"])
+		}
+		if os.IsNotExist(err) {
+			notFoundError(w, errResourceNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("loading docs failed (path: %s): %v", p.BasePath, err)
+
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		cacheHeaders(w, cacheTime)
+		securityHeaders(w)
+		if html {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		} else {
+			w.Header().Set("Content-Type", markdownContentType)
+			if contentDisposition != "" {
+				w.Header().Set("Content-Disposition", contentDisposition)
+			}
+		}
+		w.Write(body)
+	}
+}
+
+// docHasPlaceholders reads the doc given in the request path and checks if it contains placeholders for fields.
+func docHasPlaceholders(packagesBasePaths []string, vars map[string]string) (bool, error) {
+	_, err := semver.StrictNewVersion(vars["packageVersion"])
+	if err != nil {
+		return false, errResourceNotFound
+	}
+
+	docPath := filepath.Join(vars["packageName"], vars["packageVersion"], util.DirDocs, vars["doc"])
+	basePath, err := getPackageBasePath(packagesBasePaths, docPath)
+	if err != nil {
+		return false, err
+	}
+
+	source, err := ioutil.ReadFile(filepath.Join(basePath, docPath))
+	if err != nil {
+		return false, err
+	}
+	return util.HasDocPlaceholders(source), nil
+}
+
+// prefersHTML checks if an Accept header prefers HTML over Markdown. HTML has to be explicitly accepted,
+// Markdown is also accepted by wildcards.
+func prefersHTML(accept string) bool {
+	htmlQuality := 0.0
+	markdownQuality := 0.0
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, quality := parseMediaRange(mediaRange)
+		switch mediaType {
+		case "text/html":
+			htmlQuality = math.Max(htmlQuality, quality)
+		case "text/markdown", "text/*", "*/*":
+			markdownQuality = math.Max(markdownQuality, quality)
+		}
+	}
+	return htmlQuality > 0 && htmlQuality >= markdownQuality
+}
+
+// parseMediaRange returns the media type and the quality of a media range of an Accept header.
+// The quality defaults to 1, invalid qualities are handled as 0.
+func parseMediaRange(mediaRange string) (string, float64) {
+	parts := strings.Split(mediaRange, ";")
+	mediaType := strings.ToLower(strings.TrimSpace(parts[0]))
+	quality := 1.0
+	for _, param := range parts[1:] {
+		param = strings.TrimSpace(param)
+		if !strings.HasPrefix(strings.ToLower(param), "q=") {
+			continue
+		}
+		q, err := strconv.ParseFloat(param[2:], 64)
+		if err != nil || q < 0 || q > 1 {
+			q = 0
+		}
+		quality = q
+	}
+	return mediaType, quality
+}
@@ -4,11 +4,13 @@ go 1.15
 
 require (
 	github.com/Masterminds/semver/v3 v3.1.0
+	github.com/aymerick/raymond v2.0.3-0.20180322193309-b565731e1464+incompatible
 	github.com/elastic/go-ucfg v0.8.4-0.20200415140258-1232bd4774a6
 	github.com/gorilla/mux v1.7.4
 	github.com/joeshaw/multierror v0.0.0-20140124173710-69b34d4ec901
 	github.com/magefile/mage v1.9.0
 	github.com/pkg/errors v0.9.1
 	github.com/stretchr/testify v1.4.0
+	github.com/yuin/goldmark v1.4.1
 	gopkg.in/yaml.v2 v2.2.8
 )
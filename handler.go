@@ -6,12 +6,16 @@ package main
 
 import (
 	"fmt"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"time"
 
+	"github.com/Masterminds/semver/v3"
 	"github.com/pkg/errors"
+
+	"github.com/elastic/package-registry/util"
 )
 
 var errResourceNotFound = errors.New("resource not found")
@@ -43,6 +47,12 @@ func noCacheHeaders(w http.ResponseWriter) {
 	w.Header().Add("Cache-Control", "private, no-store")
 }
 
+// securityHeaders prevents browsers from running scripts of served package files in the origin of the registry.
+func securityHeaders(w http.ResponseWriter) {
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; img-src 'self' data:; style-src 'unsafe-inline'; sandbox")
+}
+
 func jsonHeader(w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "application/json")
 }
@@ -57,6 +67,7 @@ func catchAll(public http.FileSystem, cacheTime time.Duration) http.Handler {
 		}
 
 		cacheHeaders(w, cacheTime)
+		securityHeaders(w)
 
 		r.URL.Path = path
 		fileServer.ServeHTTP(w, r)
@@ -118,3 +129,34 @@ func getPackageBasePath(packagesBasePaths []string, resourcePath string) (string
 	}
 	return "", errResourceNotFound
 }
+
+// loadPackageVersion loads the given package version from the package paths. If this is not possible,
+// the error is written to the response and false is returned.
+func loadPackageVersion(w http.ResponseWriter, packagesBasePaths []string, packageName, packageVersion string) (*util.Package, bool) {
+	_, err := semver.StrictNewVersion(packageVersion)
+	if err != nil {
+		badRequest(w, "invalid package version: "+packageVersion)
+		return nil, false
+	}
+
+	packagePath, err := getPackagePath(packagesBasePaths, packageName, packageVersion)
+	if err == errResourceNotFound {
+		notFoundError(w, errPackageRevisionNotFound)
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("stat package path '%s' failed: %v", packagePath, err)
+
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	p, err := util.NewPackage(packagePath)
+	if err != nil {
+		log.Printf("loading package from path '%s' failed: %v", packagePath, err)
+
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return nil, false
+	}
+	return p, true
+}
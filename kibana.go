@@ -0,0 +1,48 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+const kibanaRouterPath = "/package/{packageName:[a-z0-9_]+}/{packageVersion}/kibana"
+
+// kibanaHandler returns the Kibana saved objects of a package with their references.
+func kibanaHandler(packagesBasePaths []string, cacheTime time.Duration) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, ok := loadPackageFromRequest(w, r, packagesBasePaths)
+		if !ok {
+			return
+		}
+
+		objects, err := p.LoadSavedObjects()
+		if err != nil {
+			log.Printf("loading saved objects failed (path: %s): %v", p.BasePath, err)
+
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		// Instead of return `null` in case of an empty array, return []
+		body := []byte("[]")
+		if len(objects) > 0 {
+			body, err = json.MarshalIndent(objects, "", "  ")
+			if err != nil {
+				log.Printf("marshaling saved objects failed (path: %s): %v", p.BasePath, err)
+
+				http.Error(w, "internal server error", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		cacheHeaders(w, cacheTime)
+		jsonHeader(w)
+		w.Write(body)
+	}
+}
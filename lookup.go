@@ -0,0 +1,67 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/elastic/package-registry/util"
+)
+
+// lookupHandler returns the package versions and data streams defining the dataset given
+// with `dataset` or the field given with `field`.
+func lookupHandler(packagesBasePaths []string, cacheTime time.Duration) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		dataset := query.Get("dataset")
+		field := query.Get("field")
+
+		if dataset == "" && field == "" {
+			badRequest(w, "missing 'dataset' or 'field' query param")
+			return
+		}
+		if dataset != "" && field != "" {
+			badRequest(w, "only one of 'dataset' or 'field' query params can be used")
+			return
+		}
+
+		index, err := util.GetLookupIndex(packagesBasePaths)
+		if err != nil {
+			notFoundError(w, errors.Wrapf(err, "fetching lookup index failed"))
+			return
+		}
+
+		var results []util.LookupResult
+		if dataset != "" {
+			results = index.Dataset(dataset)
+		} else {
+			results = index.Field(field)
+		}
+
+		data, err := getLookupOutput(results)
+		if err != nil {
+			notFoundError(w, err)
+			return
+		}
+
+		cacheHeaders(w, cacheTime)
+		jsonHeader(w)
+		fmt.Fprint(w, string(data))
+	}
+}
+
+func getLookupOutput(results []util.LookupResult) ([]byte, error) {
+	// Instead of return `null` in case of an empty array, return []
+	if len(results) == 0 {
+		return []byte("[]"), nil
+	}
+
+	return json.MarshalIndent(results, "", "  ")
+}
@@ -30,9 +30,10 @@ const (
 )
 
 var (
-	address    string
-	dryRun     bool
-	configPath = "config.yml"
+	address              string
+	dryRun               bool
+	checkBreakingChanges bool
+	configPath           = "config.yml"
 
 	defaultConfig = Config{
 		CacheTimeIndex:      10 * time.Second,
@@ -46,6 +47,7 @@ func init() {
 	flag.StringVar(&address, "address", "localhost:8080", "Address of the package-registry service.")
 	// This flag is experimental and might be removed in the future or renamed
 	flag.BoolVar(&dryRun, "dry-run", false, "Runs a dry-run of the registry without starting the web service (experimental)")
+	flag.BoolVar(&checkBreakingChanges, "check-breaking-changes", false, "Checks consecutive package versions for breaking changes without starting the web service")
 	flag.BoolVar(&util.PackageValidationDisabled, "disable-package-validation", false, "Disable package content validation")
 }
 
@@ -55,6 +57,22 @@ type Config struct {
 	CacheTimeSearch     time.Duration `config:"cache_time.search"`
 	CacheTimeCategories time.Duration `config:"cache_time.categories"`
 	CacheTimeCatchAll   time.Duration `config:"cache_time.catch_all"`
+	// ContentDisposition is the Content-Disposition header of static files that are not images, e.g. `attachment`
+	ContentDisposition string `config:"static.content_disposition"`
+	// ECSFieldsPath is the path of the ECS fields reference, in the format of `ecs_flat.yml` of an ECS version
+	ECSFieldsPath string `config:"ecs.fields_path"`
+	// CategoriesPath is the path of a YAML file with the categories of packages, replacing the built-in categories
+	CategoriesPath string `config:"categories.path"`
+}
+
+// Validate is called during Unpack of the config.
+func (c *Config) Validate() error {
+	switch c.ContentDisposition {
+	case "", "inline", "attachment":
+		return nil
+	default:
+		return fmt.Errorf("invalid static.content_disposition: %s, expected inline or attachment", c.ContentDisposition)
+	}
 }
 
 func main() {
@@ -64,6 +82,15 @@ func main() {
 
 	config := mustLoadConfig()
 	packagesBasePaths := getPackagesBasePaths(config)
+	mustLoadECSFields(config)
+	mustLoadCategories(config)
+
+	// If -check-breaking-changes=true is set, service stops here after the check
+	if checkBreakingChanges {
+		mustNotHaveBreakingChanges(packagesBasePaths)
+		return
+	}
+
 	ensurePackagesAvailable(packagesBasePaths)
 
 	// If -dry-run=true is set, service stops here after validation
@@ -129,6 +156,42 @@ func printConfig(config *Config) {
 	log.Println("Cache time for /search: ", config.CacheTimeSearch)
 	log.Println("Cache time for /categories: ", config.CacheTimeCategories)
 	log.Println("Cache time for all others: ", config.CacheTimeCatchAll)
+	if config.ContentDisposition != "" {
+		log.Println("Content disposition of static files: ", config.ContentDisposition)
+	}
+	if config.ECSFieldsPath != "" {
+		log.Printf("ECS fields path: %s\n", config.ECSFieldsPath)
+	}
+	if config.CategoriesPath != "" {
+		log.Printf("Categories path: %s\n", config.CategoriesPath)
+	}
+}
+
+func mustLoadECSFields(config *Config) {
+	if config.ECSFieldsPath == "" {
+		log.Println("ECS fields are not configured, fields of packages are not checked against ECS.")
+		return
+	}
+
+	count, err := util.LoadECSFields(config.ECSFieldsPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("%v ECS fields loaded.\n", count)
+}
+
+func mustLoadCategories(config *Config) {
+	if config.CategoriesPath == "" {
+		return
+	}
+
+	count, err := util.LoadCategories(config.CategoriesPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("%v categories loaded.\n", count)
 }
 
 func ensurePackagesAvailable(packagesBasePaths []string) {
@@ -144,6 +207,20 @@ func ensurePackagesAvailable(packagesBasePaths []string) {
 	log.Printf("%v package manifests loaded.\n", len(packages))
 }
 
+func mustNotHaveBreakingChanges(packagesBasePaths []string) {
+	packages, err := util.GetPackages(packagesBasePaths)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = packages.ValidateBreakingChanges()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("No breaking changes found in %v package manifests.\n", len(packages))
+}
+
 func mustLoadRouter(config *Config, packagesBasePaths []string) *mux.Router {
 	router, err := getRouter(config, packagesBasePaths)
 	if err != nil {
@@ -170,11 +247,26 @@ func getRouter(config *Config, packagesBasePaths []string) (*mux.Router, error)
 	router.HandleFunc("/index.json", indexHandlerFunc)
 	router.HandleFunc("/search", searchHandler(packagesBasePaths, config.CacheTimeSearch))
 	router.HandleFunc("/categories", categoriesHandler(packagesBasePaths, config.CacheTimeCategories))
+	router.HandleFunc("/lookup", lookupHandler(packagesBasePaths, config.CacheTimeSearch))
+	router.HandleFunc("/resolve", resolveHandler(packagesBasePaths, config.CacheTimeSearch))
 	router.HandleFunc("/health", healthHandler)
 	router.HandleFunc("/favicon.ico", faviconHandleFunc)
 	router.HandleFunc(artifactsRouterPath, artifactsHandler)
+	router.HandleFunc(packageResolveRouterPath, packageResolveHandler(packagesBasePaths, config.CacheTimeSearch))
+	router.HandleFunc(dataStreamFieldsRouterPath, dataStreamFieldsHandler(packagesBasePaths, config.CacheTimeCatchAll))
+	router.HandleFunc(dataStreamIndexTemplateRouterPath, dataStreamIndexTemplateHandler(packagesBasePaths, config.CacheTimeCatchAll))
+	router.HandleFunc(dataStreamIngestPipelinesRouterPath, dataStreamIngestPipelinesHandler(packagesBasePaths, config.CacheTimeCatchAll))
+	router.HandleFunc(dataStreamIngestPipelineRouterPath, dataStreamIngestPipelineHandler(packagesBasePaths, config.CacheTimeCatchAll))
+	router.HandleFunc(dataStreamRenderRouterPath, dataStreamRenderHandler(packagesBasePaths)).Methods(http.MethodPost)
+	router.HandleFunc(defaultPolicyRouterPath, defaultPolicyHandler(packagesBasePaths, config.CacheTimeCatchAll))
+	router.HandleFunc(packagePolicyValidateRouterPath, packagePolicyValidateHandler(packagesBasePaths)).Methods(http.MethodPost)
+	router.HandleFunc(docsRouterPath, docsHandler(packagesBasePaths, config.CacheTimeCatchAll, config.ContentDisposition))
+	router.HandleFunc(kibanaRouterPath, kibanaHandler(packagesBasePaths, config.CacheTimeCatchAll))
+	router.HandleFunc(compareRouterPath, compareHandler(packagesBasePaths, config.CacheTimeCatchAll))
+	router.HandleFunc(changelogRouterPath, changelogHandler(packagesBasePaths, config.CacheTimeSearch))
+	router.HandleFunc(packageVersionsRouterPath, packageVersionsHandler(packagesBasePaths, config.CacheTimeSearch))
 	router.HandleFunc(packageIndexRouterPath, packageIndexHandler)
-	router.PathPrefix("/package").HandlerFunc(staticHandler(packagesBasePaths, "/package", config.CacheTimeCatchAll))
+	router.PathPrefix("/package").HandlerFunc(staticHandler(packagesBasePaths, "/package", config.CacheTimeCatchAll, config.ContentDisposition))
 	router.Use(loggingMiddleware)
 	router.NotFoundHandler = http.Handler(notFoundHandler(fmt.Errorf("404 page not found")))
 	return router, nil
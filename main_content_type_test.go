@@ -47,4 +47,36 @@ func runContentType(t *testing.T, endpoint, expectedContentType string) {
 	})
 
 	assert.Equal(t, expectedContentType, recorder.Header().Get("Content-Type"))
+	assert.Equal(t, "nosniff", recorder.Header().Get("X-Content-Type-Options"))
+	assert.NotEmpty(t, recorder.Header().Get("Content-Security-Policy"))
+}
+
+func TestStaticContentDisposition(t *testing.T) {
+	packagesBasePaths := []string{"./testdata/package"}
+
+	tests := []struct {
+		endpoint           string
+		contentDisposition string
+		expected           string
+	}{
+		{"/package/example/1.0.0/manifest.yml", "", ""},
+		{"/package/example/1.0.0/manifest.yml", "attachment", "attachment"},
+		{"/package/example/1.0.0/img/kibana-envoyproxy.jpg", "attachment", ""},
+		{"/package/reference/1.0.0/img/icon.svg", "attachment", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.endpoint+" "+test.contentDisposition, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			h := staticHandler(packagesBasePaths, "/package", testCacheTime, test.contentDisposition)
+			h.ServeHTTP(recorder, &http.Request{
+				URL: &url.URL{
+					Path: test.endpoint,
+				},
+			})
+
+			assert.Equal(t, http.StatusOK, recorder.Code)
+			assert.Equal(t, test.expected, recorder.Header().Get("Content-Disposition"))
+		})
+	}
 }
@@ -21,6 +21,8 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/package-registry/util"
 )
 
 var (
@@ -29,6 +31,16 @@ var (
 	generatedFilesPath = filepath.Join("testdata", "generated")
 )
 
+func TestMain(m *testing.M) {
+	// Test packages import fields from ECS
+	_, err := util.LoadECSFields(filepath.Join("ecs", "1.8.0", "ecs_flat.yml"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
+
 func TestEndpoints(t *testing.T) {
 	packagesBasePaths := []string{"./testdata/second_package_path", "./testdata/package"}
 
@@ -58,12 +70,42 @@ func TestEndpoints(t *testing.T) {
 		{"/search?kibana.version=7.2.1", "/search", "search-kibana721.json", searchHandler(packagesBasePaths, testCacheTime)},
 		{"/search?category=web", "/search", "search-category-web.json", searchHandler(packagesBasePaths, testCacheTime)},
 		{"/search?category=custom", "/search", "search-category-custom.json", searchHandler(packagesBasePaths, testCacheTime)},
+		{"/search?category=cloud", "/search", "search-category-cloud.json", searchHandler(packagesBasePaths, testCacheTime)},
+		{"/search?category=cloud&include_subcategories=true", "/search", "search-category-cloud-subcategories.json", searchHandler(packagesBasePaths, testCacheTime)},
+		{"/search?category=cloud&include_subcategories=foo", "/search", "search-category-subcategories-error.txt", searchHandler(packagesBasePaths, testCacheTime)},
 		{"/search?package=example", "/search", "search-package-example.json", searchHandler(packagesBasePaths, testCacheTime)},
 		{"/search?package=example&all=true", "/search", "search-package-example-all.json", searchHandler(packagesBasePaths, testCacheTime)},
 		{"/search?internal=true", "/search", "search-package-internal.json", searchHandler(packagesBasePaths, testCacheTime)},
 		{"/search?internal=bar", "/search", "search-package-internal-error.json", searchHandler(packagesBasePaths, testCacheTime)},
 		{"/search?experimental=true", "/search", "search-package-experimental.json", searchHandler(packagesBasePaths, testCacheTime)},
 		{"/search?experimental=foo", "/search", "search-package-experimental-error.json", searchHandler(packagesBasePaths, testCacheTime)},
+		{"/resolve?package=reference@1.0.0", "/resolve", "resolve-reference.json", resolveHandler(packagesBasePaths, testCacheTime)},
+		{"/resolve?package=reference@1.0.0&kibana.version=6.8.0", "/resolve", "resolve-reference-kibana680-error.txt", resolveHandler(packagesBasePaths, testCacheTime)},
+		{"/resolve?package=reference", "/resolve", "resolve-invalid-error.txt", resolveHandler(packagesBasePaths, testCacheTime)},
+		{"/resolve?package=missing@1.0.0", "/resolve", "resolve-package-not-found.txt", resolveHandler(packagesBasePaths, testCacheTime)},
+		{"/package/multiversion/resolve", packageResolveRouterPath, "package-resolve-multiversion.json", packageResolveHandler(packagesBasePaths, testCacheTime)},
+		{"/package/multiversion/resolve?constraint=~1.0.0", packageResolveRouterPath, "package-resolve-multiversion-patch.json", packageResolveHandler(packagesBasePaths, testCacheTime)},
+		{"/package/multiversion/resolve?constraint=~1.0.0&yanked=true", packageResolveRouterPath, "package-resolve-multiversion-yanked.json", packageResolveHandler(packagesBasePaths, testCacheTime)},
+		{"/package/multiversion/resolve?constraint=^2.0", packageResolveRouterPath, "package-resolve-multiversion-not-found.txt", packageResolveHandler(packagesBasePaths, testCacheTime)},
+		{"/package/multiversion/resolve?constraint=foo", packageResolveRouterPath, "package-resolve-invalid-constraint.txt", packageResolveHandler(packagesBasePaths, testCacheTime)},
+		{"/package/example/resolve?kibana.version=6.5.0", packageResolveRouterPath, "package-resolve-example-kibana650.json", packageResolveHandler(packagesBasePaths, testCacheTime)},
+		{"/package/experimental/resolve", packageResolveRouterPath, "package-resolve-experimental-not-found.txt", packageResolveHandler(packagesBasePaths, testCacheTime)},
+		{"/package/experimental/resolve?experimental=true", packageResolveRouterPath, "package-resolve-experimental.json", packageResolveHandler(packagesBasePaths, testCacheTime)},
+		{"/package/multiversion/", packageVersionsRouterPath, "package-versions-multiversion.json", packageVersionsHandler(packagesBasePaths, testCacheTime)},
+		{"/package/example/?kibana.version=6.5.0", packageVersionsRouterPath, "package-versions-example-kibana650.json", packageVersionsHandler(packagesBasePaths, testCacheTime)},
+		{"/package/example/?kibana.version=foo", packageVersionsRouterPath, "package-versions-example-invalid-kibana.txt", packageVersionsHandler(packagesBasePaths, testCacheTime)},
+		{"/package/missing/", packageVersionsRouterPath, "package-versions-not-found.txt", packageVersionsHandler(packagesBasePaths, testCacheTime)},
+		{"/package/multiversion/changelog", changelogRouterPath, "changelog-multiversion.json", changelogHandler(packagesBasePaths, testCacheTime)},
+		{"/package/multiversion/changelog?from=1.0.3&to=1.0.4", changelogRouterPath, "changelog-multiversion-range.json", changelogHandler(packagesBasePaths, testCacheTime)},
+		{"/package/multiversion/changelog?from=1.1.0", changelogRouterPath, "changelog-multiversion-empty.json", changelogHandler(packagesBasePaths, testCacheTime)},
+		{"/package/multiversion/changelog?from=1.1.0&to=1.0.0", changelogRouterPath, "changelog-multiversion-invalid-range.txt", changelogHandler(packagesBasePaths, testCacheTime)},
+		{"/package/missing/changelog", changelogRouterPath, "changelog-package-not-found.txt", changelogHandler(packagesBasePaths, testCacheTime)},
+		{"/lookup?dataset=input_groups.ec2_logs", "/lookup", "lookup-dataset.json", lookupHandler(packagesBasePaths, testCacheTime)},
+		{"/lookup?dataset=dataset_is_prefix.test.foo", "/lookup", "lookup-dataset-prefix.json", lookupHandler(packagesBasePaths, testCacheTime)},
+		{"/lookup?dataset=unknown", "/lookup", "lookup-dataset-not-found.json", lookupHandler(packagesBasePaths, testCacheTime)},
+		{"/lookup?field=host.os.name", "/lookup", "lookup-field.json", lookupHandler(packagesBasePaths, testCacheTime)},
+		{"/lookup?field=aws.tags.owner", "/lookup", "lookup-field-wildcard.json", lookupHandler(packagesBasePaths, testCacheTime)},
+		{"/lookup", "/lookup", "lookup-missing-query.txt", lookupHandler(packagesBasePaths, testCacheTime)},
 		{"/favicon.ico", "", "favicon.ico", faviconHandleFunc},
 	}
 
@@ -122,6 +164,282 @@ func TestPackageIndex(t *testing.T) {
 	}
 }
 
+func TestCompare(t *testing.T) {
+	packagesBasePaths := []string{"./testdata/compare", "./testdata/package"}
+
+	compareHandler := compareHandler(packagesBasePaths, testCacheTime)
+
+	tests := []struct {
+		endpoint string
+		path     string
+		file     string
+		handler  func(w http.ResponseWriter, r *http.Request)
+	}{
+		{"/package/nginx/compare?from=1.0.0&to=1.1.0", compareRouterPath, "compare-nginx.json", compareHandler},
+		{"/package/example/compare?from=0.0.2&to=1.0.0", compareRouterPath, "compare-example.json", compareHandler},
+		{"/package/nginx/compare?from=1.0.0&to=9.9.9", compareRouterPath, "compare-package-revision-not-found.txt", compareHandler},
+		{"/package/nginx/compare?from=1.0.0", compareRouterPath, "compare-missing-to.txt", compareHandler},
+		{"/package/nginx/compare?from=a.b.c&to=1.1.0", compareRouterPath, "compare-invalid-version.txt", compareHandler},
+	}
+
+	for _, test := range tests {
+		t.Run(test.endpoint, func(t *testing.T) {
+			runEndpoint(t, test.endpoint, test.path, test.file, test.handler)
+		})
+	}
+}
+
+func TestDataStreams(t *testing.T) {
+	packagesBasePaths := []string{"./testdata/package"}
+
+	fieldsHandler := dataStreamFieldsHandler(packagesBasePaths, testCacheTime)
+	indexTemplateHandler := dataStreamIndexTemplateHandler(packagesBasePaths, testCacheTime)
+	ingestPipelinesHandler := dataStreamIngestPipelinesHandler(packagesBasePaths, testCacheTime)
+	ingestPipelineHandler := dataStreamIngestPipelineHandler(packagesBasePaths, testCacheTime)
+
+	tests := []struct {
+		endpoint string
+		path     string
+		file     string
+		handler  func(w http.ResponseWriter, r *http.Request)
+	}{
+		{"/package/input_groups/0.0.1/data_stream/ec2_logs/fields", dataStreamFieldsRouterPath, "fields-input-groups-ec2-logs.json", fieldsHandler},
+		{"/package/input_groups/0.0.1/data_stream/ec2_logs/fields?format=csv", dataStreamFieldsRouterPath, "fields-input-groups-ec2-logs.csv", fieldsHandler},
+		{"/package/ecs_style_dataset/0.0.1/data_stream/foo/fields", dataStreamFieldsRouterPath, "fields-ecs-style-dataset.json", fieldsHandler},
+		{"/package/ecs_style_dataset/0.0.1/data_stream/foo/fields?format=xml", dataStreamFieldsRouterPath, "fields-invalid-format.txt", fieldsHandler},
+		{"/package/ecs_style_dataset/0.0.1/data_stream/missing/fields", dataStreamFieldsRouterPath, "fields-data-stream-not-found.txt", fieldsHandler},
+		{"/package/missing/1.0.0/data_stream/foo/fields", dataStreamFieldsRouterPath, "fields-package-not-found.txt", fieldsHandler},
+		{"/package/reference/1.0.0/data_stream/reference/index_template", dataStreamIndexTemplateRouterPath, "index-template-reference.json", indexTemplateHandler},
+		{"/package/yamlpipeline/1.0.0/data_stream/log/index_template", dataStreamIndexTemplateRouterPath, "index-template-yamlpipeline.json", indexTemplateHandler},
+		{"/package/hidden/1.0.0/data_stream/hidden/index_template", dataStreamIndexTemplateRouterPath, "index-template-hidden.json", indexTemplateHandler},
+		{"/package/input_groups/0.0.1/data_stream/ec2_metrics/index_template", dataStreamIndexTemplateRouterPath, "index-template-input-groups-ec2-metrics.json", indexTemplateHandler},
+		{"/package/dataset_is_prefix/0.0.1/data_stream/test/index_template", dataStreamIndexTemplateRouterPath, "index-template-dataset-is-prefix.json", indexTemplateHandler},
+		{"/package/reference/1.0.0/data_stream/missing/index_template", dataStreamIndexTemplateRouterPath, "index-template-data-stream-not-found.txt", indexTemplateHandler},
+		{"/package/yamlpipeline/1.0.0/data_stream/log/ingest_pipelines", dataStreamIngestPipelinesRouterPath, "ingest-pipelines-yamlpipeline.json", ingestPipelinesHandler},
+		{"/package/reference/1.0.0/data_stream/reference/ingest_pipelines", dataStreamIngestPipelinesRouterPath, "ingest-pipelines-empty.json", ingestPipelinesHandler},
+		{"/package/example/1.0.0/data_stream/foo/ingest_pipelines/pipeline-entry", dataStreamIngestPipelineRouterPath, "ingest-pipeline-example-entry.json", ingestPipelineHandler},
+		{"/package/input_groups/0.0.1/data_stream/ec2_logs/ingest_pipelines/default", dataStreamIngestPipelineRouterPath, "ingest-pipeline-input-groups-default.json", ingestPipelineHandler},
+		{"/package/example/1.0.0/data_stream/foo/ingest_pipelines/missing", dataStreamIngestPipelineRouterPath, "ingest-pipeline-not-found.txt", ingestPipelineHandler},
+	}
+
+	for _, test := range tests {
+		t.Run(test.endpoint, func(t *testing.T) {
+			runEndpoint(t, test.endpoint, test.path, test.file, test.handler)
+		})
+	}
+}
+
+func TestDataStreamRender(t *testing.T) {
+	packagesBasePaths := []string{"./testdata/package"}
+
+	renderHandler := dataStreamRenderHandler(packagesBasePaths)
+
+	tests := []struct {
+		endpoint string
+		body     string
+		file     string
+	}{
+		{"/package/input_groups/0.0.1/data_stream/ec2_logs/render", `{"queue_url": "https://sqs.example.com/queue?a=1&b=2", "role_arn": "arn:aws:iam::123:role/o'reilly"}`, "render-input-groups-ec2-logs.yml"},
+		{"/package/input_groups/0.0.1/data_stream/ec2_logs/render?input=s3", `{"queue_url": "https://sqs.example.com/queue", "fips_enabled": true, "endpoint": null}`, "render-input-groups-ec2-logs-input.yml"},
+		{"/package/input_groups/0.0.1/data_stream/ec2_logs/render", `{}`, "render-missing-required.txt"},
+		{"/package/input_groups/0.0.1/data_stream/ec2_logs/render", `{"queue_url": "foo", "unknown": 1}`, "render-unknown-variable.txt"},
+		{"/package/input_groups/0.0.1/data_stream/ec2_logs/render", `[1, 2]`, "render-invalid-body.txt"},
+		{"/package/input_groups/0.0.1/data_stream/ec2_logs/render?input=missing", `{}`, "render-stream-not-found.txt"},
+		{"/package/example/1.0.0/data_stream/foo/render", `{"paths": ["/var/log/*.log"]}`, "render-example.yml"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.endpoint+" "+test.body, func(t *testing.T) {
+			runEndpointWithBody(t, http.MethodPost, test.endpoint, dataStreamRenderRouterPath, test.file, test.body, renderHandler)
+		})
+	}
+}
+
+func TestPackagePolicyValidate(t *testing.T) {
+	packagesBasePaths := []string{"./testdata/package"}
+
+	validateHandler := packagePolicyValidateHandler(packagesBasePaths)
+
+	tests := []struct {
+		endpoint string
+		body     string
+		file     string
+	}{
+		{
+			"/package/input_groups/0.0.1/policy/validate",
+			`{"vars": {"endpoint": "amazonaws.com"}, "inputs": [{"policy_template": "ec2", "type": "s3", "streams": [{"data_stream": "ec2_logs", "vars": {"queue_url": "https://sqs.example.com"}}]}]}`,
+			"policy-validate-valid.json",
+		},
+		{
+			"/package/input_groups/0.0.1/policy/validate",
+			`{"vars": {"endpoint": 1, "region": "eu"}, "inputs": [{"policy_template": "ec2", "type": "s3", "streams": [{"data_stream": "ec2_logs", "vars": {"fips_enabled": "yes"}}]}, {"policy_template": "ec2", "type": "unknown"}]}`,
+			"policy-validate-invalid.json",
+		},
+		{
+			"/package/input_groups/0.0.1/policy/validate",
+			`{"inputs": [{"policy_template": "ec2", "type": "s3", "enabled": false}], "output": "default"}`,
+			"policy-validate-invalid-body.txt",
+		},
+		{
+			"/package/input_groups/9.9.9/policy/validate",
+			`{}`,
+			"policy-validate-package-not-found.txt",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.file, func(t *testing.T) {
+			runEndpointWithBody(t, http.MethodPost, test.endpoint, packagePolicyValidateRouterPath, test.file, test.body, validateHandler)
+		})
+	}
+}
+
+func TestDefaultPolicy(t *testing.T) {
+	packagesBasePaths := []string{"./testdata/package"}
+
+	defaultPolicyHandler := defaultPolicyHandler(packagesBasePaths, testCacheTime)
+
+	tests := []struct {
+		endpoint string
+		file     string
+	}{
+		{"/package/input_groups/0.0.1/policy_template/ec2/default_policy", "default-policy-input-groups.json"},
+		{"/package/input_groups/0.0.1/policy_template/ec2/default_policy?disable=s3", "default-policy-input-groups-disable.json"},
+		{"/package/input_groups/0.0.1/policy_template/ec2/default_policy?enable=s3", "default-policy-input-groups-enable.json"},
+		{"/package/input_groups/0.0.1/policy_template/ec2/default_policy?enable=s3&disable=aws/metrics", "default-policy-enable-and-disable.txt"},
+		{"/package/input_groups/0.0.1/policy_template/ec2/default_policy?enable=unknown", "default-policy-unknown-input.txt"},
+		{"/package/input_groups/0.0.1/policy_template/missing/default_policy", "default-policy-not-found.txt"},
+		{"/package/multiple_false/0.0.1/policy_template/logs/default_policy", "default-policy-multiple-false.json"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.endpoint, func(t *testing.T) {
+			runEndpoint(t, test.endpoint, defaultPolicyRouterPath, test.file, defaultPolicyHandler)
+		})
+	}
+}
+
+func TestDocs(t *testing.T) {
+	packagesBasePaths := []string{"./testdata/package"}
+
+	docsHandler := docsHandler(packagesBasePaths, testCacheTime, "")
+
+	tests := []struct {
+		endpoint string
+		file     string
+	}{
+		{"/package/longdocs/1.0.4/docs/README.md?format=html", "docs-longdocs.html"},
+		{"/package/longdocs/1.0.4/docs/README.md", "docs-longdocs.md"},
+		{"/package/longdocs/1.0.4/docs/README.md?format=markdown", "docs-longdocs.md"},
+		{"/package/reference/1.0.0/docs/README.md", "docs-reference.md"},
+		{"/package/reference/1.0.0/docs/README.md?format=html", "docs-reference.html"},
+		{"/package/longdocs/1.0.4/docs/README.md?format=pdf", "docs-invalid-format.txt"},
+		{"/package/longdocs/1.0.4/docs/missing.md?format=html", "docs-not-found.txt"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.endpoint, func(t *testing.T) {
+			runEndpoint(t, test.endpoint, docsRouterPath, test.file, docsHandler)
+		})
+	}
+}
+
+func TestDocsAcceptHTML(t *testing.T) {
+	packagesBasePaths := []string{"./testdata/package"}
+
+	router := mux.NewRouter()
+	router.HandleFunc(docsRouterPath, docsHandler(packagesBasePaths, testCacheTime, ""))
+
+	req, err := http.NewRequest(http.MethodGet, "/package/longdocs/1.0.4/docs/README.md", nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "text/html; charset=utf-8", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, "Accept", recorder.Header().Get("Vary"))
+	assert.Contains(t, recorder.Body.String(), `<nav class="toc">`)
+}
+
+func TestPrefersHTML(t *testing.T) {
+	tests := []struct {
+		accept   string
+		expected bool
+	}{
+		{"", false},
+		{"*/*", false},
+		{"text/html", true},
+		{"text/html;q=0", false},
+		{"text/html; q=0.5, text/markdown", false},
+		{"text/markdown;q=0.5, text/html", true},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", true},
+		{"text/html;q=invalid", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.accept, func(t *testing.T) {
+			assert.Equal(t, test.expected, prefersHTML(test.accept))
+		})
+	}
+}
+
+func TestDocsMarkdown(t *testing.T) {
+	packagesBasePaths := []string{"./testdata/package"}
+
+	router := mux.NewRouter()
+	router.HandleFunc(docsRouterPath, docsHandler(packagesBasePaths, testCacheTime, "attachment"))
+
+	// Docs without placeholders are served as static files
+	req, err := http.NewRequest(http.MethodGet, "/package/longdocs/1.0.4/docs/README.md", nil)
+	require.NoError(t, err)
+	req.Header.Set("Range", "bytes=0-9")
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusPartialContent, recorder.Code)
+	assert.Equal(t, "text/markdown; charset=utf-8", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, "Accept", recorder.Header().Get("Vary"))
+	assert.Equal(t, "attachment", recorder.Header().Get("Content-Disposition"))
+	assert.NotEmpty(t, recorder.Header().Get("Last-Modified"))
+	assert.Len(t, recorder.Body.String(), 10)
+
+	// Docs with placeholders are served with the fields tables
+	req, err = http.NewRequest(http.MethodGet, "/package/reference/1.0.0/docs/README.md", nil)
+	require.NoError(t, err)
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "text/markdown; charset=utf-8", recorder.Header().Get("Content-Type"))
+	assert.Equal(t, "attachment", recorder.Header().Get("Content-Disposition"))
+	assert.Contains(t, recorder.Body.String(), "| Field | Description | Type |")
+}
+
+func TestKibana(t *testing.T) {
+	packagesBasePaths := []string{"./testdata/package"}
+
+	kibanaHandler := kibanaHandler(packagesBasePaths, testCacheTime)
+
+	tests := []struct {
+		endpoint string
+		file     string
+	}{
+		{"/package/example/1.0.0/kibana", "kibana-example.json"},
+		{"/package/reference/1.0.0/kibana", "kibana-no-objects.json"},
+		{"/package/missing/1.0.0/kibana", "kibana-missing-package.txt"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.endpoint, func(t *testing.T) {
+			runEndpoint(t, test.endpoint, kibanaRouterPath, test.file, kibanaHandler)
+		})
+	}
+}
+
 // TestAllPackageIndex generates and compares all index.json files for the test packages
 func TestAllPackageIndex(t *testing.T) {
 	testPackagePath := filepath.Join("testdata", "package")
@@ -161,7 +479,11 @@ func TestAllPackageIndex(t *testing.T) {
 }
 
 func runEndpoint(t *testing.T, endpoint, path, file string, handler func(w http.ResponseWriter, r *http.Request)) {
-	req, err := http.NewRequest("GET", endpoint, nil)
+	runEndpointWithBody(t, http.MethodGet, endpoint, path, file, "", handler)
+}
+
+func runEndpointWithBody(t *testing.T, method, endpoint, path, file, body string, handler func(w http.ResponseWriter, r *http.Request)) {
+	req, err := http.NewRequest(method, endpoint, strings.NewReader(body))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -199,8 +521,8 @@ func runEndpoint(t *testing.T, endpoint, path, file string, handler func(w http.
 
 	assert.Equal(t, bytes.TrimSpace(data), bytes.TrimSpace(recorded))
 
-	// Skip cache check if 4xx error
-	if recorder.Code >= 200 && recorder.Code < 300 {
+	// Skip cache check if 4xx error or the request is not cacheable
+	if recorder.Code >= 200 && recorder.Code < 300 && method == http.MethodGet {
 		cacheTime := fmt.Sprintf("%.0f", testCacheTime.Seconds())
 		assert.Equal(t, recorder.Header()["Cache-Control"], []string{"max-age=" + cacheTime, "public"})
 	}
@@ -0,0 +1,116 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"github.com/elastic/package-registry/util"
+)
+
+const (
+	packageResolveRouterPath = "/package/{packageName:[a-z0-9_]+}/resolve"
+)
+
+var errPackageVersionNotResolved = errors.New("no package version satisfies the constraints")
+
+// packageResolveHandler returns the newest version of a package which satisfies the given
+// version constraint and is compatible with the given Kibana version.
+func packageResolveHandler(packagesBasePaths []string, cacheTime time.Duration) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		packageName, ok := vars["packageName"]
+		if !ok {
+			badRequest(w, "missing package name")
+			return
+		}
+
+		query := r.URL.Query()
+
+		var constraint *semver.Constraints
+		var kibanaVersion *semver.Version
+		var experimental bool
+		var yanked bool
+		var err error
+
+		// Read query filter params which can affect the output
+		if len(query) > 0 {
+			if v := query.Get("constraint"); v != "" {
+				constraint, err = semver.NewConstraint(v)
+				if err != nil {
+					badRequest(w, fmt.Sprintf("invalid version constraint '%s': %s", v, err))
+					return
+				}
+			}
+
+			if v := query.Get("kibana.version"); v != "" {
+				kibanaVersion, err = semver.NewVersion(v)
+				if err != nil {
+					badRequest(w, fmt.Sprintf("invalid Kibana version '%s': %s", v, err))
+					return
+				}
+			}
+
+			if v := query.Get("experimental"); v != "" {
+				experimental, err = strconv.ParseBool(v)
+				if err != nil {
+					badRequest(w, fmt.Sprintf("invalid 'experimental' query param: '%s'", v))
+					return
+				}
+			}
+
+			if v := query.Get("yanked"); v != "" {
+				yanked, err = strconv.ParseBool(v)
+				if err != nil {
+					badRequest(w, fmt.Sprintf("invalid 'yanked' query param: '%s'", v))
+					return
+				}
+			}
+		}
+
+		packages, err := util.GetPackages(packagesBasePaths)
+		if err != nil {
+			notFoundError(w, errors.Wrapf(err, "fetching package failed"))
+			return
+		}
+
+		p := packages.Newest(packageName, func(p util.Package) bool {
+			// Skip experimental packages if flag is not specified
+			if p.Release == util.ReleaseExperimental && !experimental {
+				return false
+			}
+
+			// Skip yanked packages if flag is not specified
+			if p.Yanked && !yanked {
+				return false
+			}
+
+			return p.MatchesVersion(constraint) && p.HasKibanaVersion(kibanaVersion)
+		})
+		if p == nil {
+			notFoundError(w, errPackageVersionNotResolved)
+			return
+		}
+
+		data, err := json.MarshalIndent(p.BasePackage, "", "  ")
+		if err != nil {
+			notFoundError(w, err)
+			return
+		}
+
+		cacheHeaders(w, cacheTime)
+		jsonHeader(w)
+		w.Header().Set("Link", fmt.Sprintf(`<%s/>; rel="related"`, p.Path))
+		fmt.Fprint(w, string(data))
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"github.com/elastic/package-registry/util"
+)
+
+const (
+	packageVersionsRouterPath = "/package/{packageName:[a-z0-9_]+}/"
+)
+
+var errPackageNotFound = errors.New("package not found")
+
+type packageVersion struct {
+	Version       string           `json:"version"`
+	Release       string           `json:"release"`
+	FormatVersion string           `json:"format_version"`
+	Conditions    *util.Conditions `json:"conditions,omitempty"`
+	Download      string           `json:"download"`
+	Path          string           `json:"path"`
+	Yanked        bool             `json:"yanked,omitempty"`
+	// Compatible is only set if a Kibana version is given
+	Compatible *bool `json:"compatible,omitempty"`
+}
+
+// packageVersionsHandler lists all versions of a package together with the information
+// needed to decide which version to install.
+func packageVersionsHandler(packagesBasePaths []string, cacheTime time.Duration) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		packageName, ok := vars["packageName"]
+		if !ok {
+			badRequest(w, "missing package name")
+			return
+		}
+
+		query := r.URL.Query()
+
+		var kibanaVersion *semver.Version
+		var err error
+
+		if v := query.Get("kibana.version"); v != "" {
+			kibanaVersion, err = semver.NewVersion(v)
+			if err != nil {
+				badRequest(w, fmt.Sprintf("invalid Kibana version '%s': %s", v, err))
+				return
+			}
+		}
+
+		packages, err := util.GetPackages(packagesBasePaths)
+		if err != nil {
+			notFoundError(w, errors.Wrapf(err, "fetching package failed"))
+			return
+		}
+
+		var versions util.Packages
+		seen := map[string]bool{}
+		for _, p := range packages {
+			// The first package path containing a version wins
+			if p.Name != packageName || seen[p.Version] {
+				continue
+			}
+			seen[p.Version] = true
+			versions = append(versions, p)
+		}
+
+		if len(versions) == 0 {
+			notFoundError(w, errPackageNotFound)
+			return
+		}
+
+		sort.Slice(versions, func(i, j int) bool {
+			return !versions[i].IsNewerOrEqual(versions[j])
+		})
+
+		data, err := getPackageVersionsOutput(versions, kibanaVersion)
+		if err != nil {
+			notFoundError(w, err)
+			return
+		}
+
+		cacheHeaders(w, cacheTime)
+		jsonHeader(w)
+		fmt.Fprint(w, string(data))
+	}
+}
+
+func getPackageVersionsOutput(versions util.Packages, kibanaVersion *semver.Version) ([]byte, error) {
+	var output []packageVersion
+	for _, p := range versions {
+		v := packageVersion{
+			Version:       p.Version,
+			Release:       p.Release,
+			FormatVersion: p.FormatVersion,
+			Conditions:    p.Conditions,
+			Download:      p.Download,
+			Path:          p.Path,
+			Yanked:        p.Yanked,
+		}
+
+		if kibanaVersion != nil {
+			compatible := p.HasKibanaVersion(kibanaVersion)
+			v.Compatible = &compatible
+		}
+		output = append(output, v)
+	}
+	return json.MarshalIndent(output, "", "  ")
+}
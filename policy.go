@@ -0,0 +1,164 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"github.com/elastic/package-registry/util"
+)
+
+const (
+	packagePolicyValidateRouterPath = "/package/{packageName:[a-z0-9_]+}/{packageVersion}/policy/validate"
+	defaultPolicyRouterPath         = "/package/{packageName:[a-z0-9_]+}/{packageVersion}/policy_template/{policyTemplate}/default_policy"
+)
+
+var errPolicyTemplateNotFound = errors.New("policy template not found")
+
+type policyValidationResult struct {
+	Valid  bool               `json:"valid"`
+	Errors []util.PolicyError `json:"errors"`
+}
+
+// packagePolicyValidateHandler validates the variable values of the package policy given in the request body.
+func packagePolicyValidateHandler(packagesBasePaths []string) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, ok := loadPackageFromRequest(w, r, packagesBasePaths)
+		if !ok {
+			return
+		}
+
+		var policy util.PackagePolicy
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+		err := decoder.Decode(&policy)
+		if err != nil {
+			badRequest(w, fmt.Sprintf("invalid package policy in request body: %v", err))
+			return
+		}
+
+		result := policyValidationResult{
+			Errors: p.ValidatePolicy(policy),
+		}
+		result.Valid = len(result.Errors) == 0
+		// Instead of return `null` in case of an empty array, return []
+		if result.Errors == nil {
+			result.Errors = []util.PolicyError{}
+		}
+
+		body, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			log.Printf("marshaling policy validation result failed (package: %s): %v", p.Name, err)
+
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		noCacheHeaders(w)
+		jsonHeader(w)
+		w.Write(body)
+	}
+}
+
+// defaultPolicyHandler returns the default package policy of a policy template. All inputs are enabled, unless
+// `enable` or `disable` query params are given with comma-separated lists of input types.
+func defaultPolicyHandler(packagesBasePaths []string, cacheTime time.Duration) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		enable := splitQueryList(query.Get("enable"))
+		disable := splitQueryList(query.Get("disable"))
+		if len(enable) > 0 && len(disable) > 0 {
+			badRequest(w, "only one of 'enable' or 'disable' query params can be used")
+			return
+		}
+
+		p, ok := loadPackageFromRequest(w, r, packagesBasePaths)
+		if !ok {
+			return
+		}
+
+		policy := p.DefaultPolicy(mux.Vars(r)["policyTemplate"], func(inputType string) bool {
+			if len(enable) > 0 {
+				return enable[inputType]
+			}
+			return !disable[inputType]
+		})
+		if policy == nil {
+			notFoundError(w, errPolicyTemplateNotFound)
+			return
+		}
+
+		for inputType := range enable {
+			if !hasPolicyInput(policy, inputType) {
+				badRequest(w, fmt.Sprintf("unknown input in 'enable' query param: '%s'", inputType))
+				return
+			}
+		}
+		for inputType := range disable {
+			if !hasPolicyInput(policy, inputType) {
+				badRequest(w, fmt.Sprintf("unknown input in 'disable' query param: '%s'", inputType))
+				return
+			}
+		}
+
+		body, err := json.MarshalIndent(policy, "", "  ")
+		if err != nil {
+			log.Printf("marshaling default policy failed (package: %s): %v", p.Name, err)
+
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		cacheHeaders(w, cacheTime)
+		jsonHeader(w)
+		w.Write(body)
+	}
+}
+
+func splitQueryList(value string) map[string]bool {
+	values := map[string]bool{}
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values[v] = true
+		}
+	}
+	return values
+}
+
+func hasPolicyInput(policy *util.PackagePolicy, inputType string) bool {
+	for _, i := range policy.Inputs {
+		if i.Type == inputType {
+			return true
+		}
+	}
+	return false
+}
+
+// loadPackageFromRequest loads the package version given in the request path. If this is not possible,
+// the error is written to the response and false is returned.
+func loadPackageFromRequest(w http.ResponseWriter, r *http.Request, packagesBasePaths []string) (*util.Package, bool) {
+	vars := mux.Vars(r)
+	packageName, ok := vars["packageName"]
+	if !ok {
+		badRequest(w, "missing package name")
+		return nil, false
+	}
+
+	packageVersion, ok := vars["packageVersion"]
+	if !ok {
+		badRequest(w, "missing package version")
+		return nil, false
+	}
+
+	return loadPackageVersion(w, packagesBasePaths, packageName, packageVersion)
+}
@@ -0,0 +1,103 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/pkg/errors"
+
+	"github.com/elastic/package-registry/util"
+)
+
+// resolveHandler returns the list of packages which have to be installed for the package
+// given as `package={name}@{version}`, including its requirements.
+func resolveHandler(packagesBasePaths []string, cacheTime time.Duration) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		var kibanaVersion *semver.Version
+		var err error
+
+		v := query.Get("package")
+		if v == "" {
+			badRequest(w, "missing 'package' query param")
+			return
+		}
+
+		parts := strings.SplitN(v, "@", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			badRequest(w, fmt.Sprintf("invalid 'package' query param, expected {name}@{version}: '%s'", v))
+			return
+		}
+		packageName, packageVersion := parts[0], parts[1]
+
+		_, err = semver.StrictNewVersion(packageVersion)
+		if err != nil {
+			badRequest(w, "invalid package version")
+			return
+		}
+
+		if v := query.Get("kibana.version"); v != "" {
+			kibanaVersion, err = semver.NewVersion(v)
+			if err != nil {
+				badRequest(w, fmt.Sprintf("invalid Kibana version '%s': %s", v, err))
+				return
+			}
+		}
+
+		packages, err := util.GetPackages(packagesBasePaths)
+		if err != nil {
+			notFoundError(w, errors.Wrapf(err, "fetching package failed"))
+			return
+		}
+
+		var p *util.Package
+		for i := range packages {
+			if packages[i].Name == packageName && packages[i].Version == packageVersion {
+				p = &packages[i]
+				break
+			}
+		}
+		if p == nil {
+			notFoundError(w, errPackageRevisionNotFound)
+			return
+		}
+
+		if !p.HasKibanaVersion(kibanaVersion) {
+			badRequest(w, fmt.Sprintf("package %s is not compatible with Kibana %s", v, kibanaVersion.String()))
+			return
+		}
+
+		resolved, err := packages.ResolveRequirements(*p, kibanaVersion)
+		if err != nil {
+			badRequest(w, fmt.Sprintf("resolving requirements failed: %s", err))
+			return
+		}
+
+		data, err := getResolveOutput(resolved)
+		if err != nil {
+			notFoundError(w, err)
+			return
+		}
+
+		cacheHeaders(w, cacheTime)
+		jsonHeader(w)
+		fmt.Fprint(w, string(data))
+	}
+}
+
+func getResolveOutput(packages util.Packages) ([]byte, error) {
+	output := make([]util.BasePackage, 0, len(packages))
+	for _, p := range packages {
+		output = append(output, p.BasePackage)
+	}
+	return json.MarshalIndent(output, "", "  ")
+}
@@ -25,6 +25,7 @@ func searchHandler(packagesBasePaths []string, cacheTime time.Duration) func(w h
 
 		var kibanaVersion *semver.Version
 		var category string
+		var includeSubcategories bool
 		// Leaving out `a` here to not use a reserved name
 		var packageQuery string
 		var all bool
@@ -46,6 +47,14 @@ func searchHandler(packagesBasePaths []string, cacheTime time.Duration) func(w h
 				category = v
 			}
 
+			if v := query.Get("include_subcategories"); v != "" {
+				includeSubcategories, err = strconv.ParseBool(v)
+				if err != nil {
+					badRequest(w, fmt.Sprintf("invalid 'include_subcategories' query param: '%s'", v))
+					return
+				}
+			}
+
 			if v := query.Get("package"); v != "" {
 				packageQuery = v
 			}
@@ -101,8 +110,13 @@ func searchHandler(packagesBasePaths []string, cacheTime time.Duration) func(w h
 			// Filter by category first as this could heavily reduce the number of packages
 			// It must happen before the version filtering as there only the newest version
 			// is exposed and there could be an older package with more versions.
-			if category != "" && !p.HasCategory(category) {
-				continue
+			if category != "" {
+				if includeSubcategories && !p.HasCategoryOrSubcategory(category) {
+					continue
+				}
+				if !includeSubcategories && !p.HasCategory(category) {
+					continue
+				}
 			}
 
 			if kibanaVersion != nil {
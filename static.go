@@ -6,11 +6,16 @@ package main
 
 import (
 	"log"
+	"mime"
 	"net/http"
+	"path"
+	"strings"
 	"time"
 )
 
-func staticHandler(packagesBasePaths []string, prefix string, cacheTime time.Duration) http.HandlerFunc {
+// staticHandler serves the files of the packages. If contentDisposition is set, it is used as Content-Disposition
+// header of all files that are not images.
+func staticHandler(packagesBasePaths []string, prefix string, cacheTime time.Duration, contentDisposition string) http.HandlerFunc {
 	fileServers := map[string]http.Handler{}
 	for _, packagesBasePath := range packagesBasePaths {
 		fileServers[packagesBasePath] = catchAll(http.Dir(packagesBasePath), cacheTime)
@@ -28,6 +33,13 @@ func staticHandler(packagesBasePaths []string, prefix string, cacheTime time.Dur
 			return
 		}
 
+		if contentDisposition != "" && !isImage(r.URL.Path) {
+			w.Header().Set("Content-Disposition", contentDisposition)
+		}
 		fileServers[basePath].ServeHTTP(w, r)
 	})).ServeHTTP
 }
+
+func isImage(filePath string) bool {
+	return strings.HasPrefix(mime.TypeByExtension(path.Ext(filePath)), "image/")
+}
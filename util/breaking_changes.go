@@ -0,0 +1,107 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package util
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/joeshaw/multierror"
+	"github.com/pkg/errors"
+)
+
+// BreakingChanges returns the backward incompatible changes between two versions of a package.
+func BreakingChanges(from, to *Package) ([]string, error) {
+	c, err := compareStructure(from, to)
+	if err != nil {
+		return nil, err
+	}
+	return c.breakingChanges(), nil
+}
+
+func (c *PackageComparison) breakingChanges() []string {
+	var changes []string
+	for _, d := range c.DataStreams.Removed {
+		changes = append(changes, fmt.Sprintf("data stream removed: %s", d))
+	}
+
+	for _, i := range c.Inputs.Removed {
+		changes = append(changes, fmt.Sprintf("input removed: %s", i))
+	}
+
+	for _, f := range c.Fields {
+		switch f.Change {
+		case ChangeRemoved:
+			changes = append(changes, fmt.Sprintf("field removed: %s (data stream: %s)", f.Name, f.DataStream))
+		case ChangeChanged:
+			changes = append(changes, fmt.Sprintf("field type changed from %s to %s: %s (data stream: %s)", f.FromType, f.ToType, f.Name, f.DataStream))
+		}
+	}
+
+	for _, v := range c.Vars {
+		if v.To == nil || !v.To.Required || v.To.Default != nil {
+			continue
+		}
+
+		switch {
+		case v.Change == ChangeAdded:
+			changes = append(changes, fmt.Sprintf("required variable without default added: %s (scope: %s)", v.Name, v.Scope))
+		case v.Change == ChangeChanged && !v.From.Required:
+			changes = append(changes, fmt.Sprintf("variable without default made required: %s (scope: %s)", v.Name, v.Scope))
+		}
+	}
+	return changes
+}
+
+// ValidateBreakingChanges compares each package version with the previous version of the same package.
+// Breaking changes are only allowed if the major version was increased.
+func (packages Packages) ValidateBreakingChanges() error {
+	var errs multierror.Errors
+	for _, versions := range packages.consecutiveVersions() {
+		for i := 1; i < len(versions); i++ {
+			from, to := versions[i-1], versions[i]
+			if to.versionSemVer.Major() > from.versionSemVer.Major() {
+				continue
+			}
+
+			changes, err := BreakingChanges(&from, &to)
+			if err != nil {
+				errs = append(errs, errors.Wrapf(err, "comparing package %s versions %s and %s failed", to.Name, from.Version, to.Version))
+				continue
+			}
+
+			if len(changes) > 0 {
+				errs = append(errs, fmt.Errorf("breaking changes in package %s %s require a new major version (previous version: %s): %s",
+					to.Name, to.Version, from.Version, strings.Join(changes, ", ")))
+			}
+		}
+	}
+	return errs.Err()
+}
+
+// consecutiveVersions groups the packages by name, with the versions of each package sorted
+// from oldest to newest. If a version exists multiple times, the first one wins.
+func (packages Packages) consecutiveVersions() [][]Package {
+	byName := map[string]Packages{}
+	seen := map[string]bool{}
+	for _, p := range packages {
+		if seen[p.Name+"@"+p.Version] {
+			continue
+		}
+		seen[p.Name+"@"+p.Version] = true
+		byName[p.Name] = append(byName[p.Name], p)
+	}
+
+	var grouped [][]Package
+	for _, name := range sortedKeys(byName) {
+		versions := byName[name]
+		sort.Slice(versions, func(i, j int) bool {
+			return versions[i].versionSemVer.LessThan(versions[j].versionSemVer)
+		})
+		grouped = append(grouped, versions)
+	}
+	return grouped
+}
@@ -0,0 +1,44 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package util
+
+import (
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBreakingChanges(t *testing.T) {
+	from, err := NewPackage("../testdata/compare/nginx/1.0.0")
+	require.NoError(t, err)
+
+	to, err := NewPackage("../testdata/compare/nginx/1.1.0")
+	require.NoError(t, err)
+
+	changes, err := BreakingChanges(from, to)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"data stream removed: status",
+		"input removed: policy_template.nginx.input.nginx/metrics",
+		"field type changed from ip to keyword: nginx.access.remote_ip (data stream: access)",
+		"field removed: nginx.access.user_name (data stream: access)",
+		"required variable without default added: tags (scope: data_stream.access.stream.0.logfile)",
+	}, changes)
+
+	packages := Packages{*from, *to}
+	assert.Error(t, packages.ValidateBreakingChanges())
+
+	// A new major version is allowed to contain breaking changes
+	packages[1].versionSemVer = semver.MustParse("2.0.0")
+	assert.NoError(t, packages.ValidateBreakingChanges())
+
+	// A new minor version isn't enough, also for versions 0.x
+	packages[0].versionSemVer = semver.MustParse("0.1.0")
+	packages[1].versionSemVer = semver.MustParse("0.2.0")
+	assert.Error(t, packages.ValidateBreakingChanges())
+}
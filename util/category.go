@@ -0,0 +1,180 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package util
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+
+	"github.com/joeshaw/multierror"
+	"github.com/pkg/errors"
+	yamlv2 "gopkg.in/yaml.v2"
+)
+
+var categoryIDPattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// Category is a category packages can be assigned to.
+type Category struct {
+	ID          string `yaml:"id" json:"id"`
+	Title       string `yaml:"title" json:"title"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+	// Icon is the name or URL of the icon shown for the category
+	Icon string `yaml:"icon,omitempty" json:"icon,omitempty"`
+	// Parent is the ID of the parent category, empty for top-level categories
+	Parent string `yaml:"parent_id,omitempty" json:"parent_id,omitempty"`
+}
+
+// defaultCategories are the categories used if no categories file is configured.
+var defaultCategories = []Category{
+	{ID: "aws", Title: "AWS", Parent: "cloud"},
+	{ID: "azure", Title: "Azure", Parent: "cloud"},
+	{ID: "cloud", Title: "Cloud"},
+	{ID: "config_management", Title: "Config management"},
+	{ID: "containers", Title: "Containers"},
+	{ID: "crm", Title: "CRM"},
+	{ID: "custom", Title: "Custom"},
+	{ID: "datastore", Title: "Datastore"},
+	{ID: "elastic_stack", Title: "Elastic Stack"},
+	{ID: "google_cloud", Title: "Google Cloud", Parent: "cloud"},
+	{ID: "kubernetes", Title: "Kubernetes", Parent: "containers"},
+	{ID: "languages", Title: "Languages"},
+	{ID: "message_queue", Title: "Message Queue"},
+	{ID: "monitoring", Title: "Monitoring"},
+	{ID: "network", Title: "Network"},
+	{ID: "notification", Title: "Notification"},
+	{ID: "os_system", Title: "OS & System"},
+	{ID: "productivity", Title: "Productivity"},
+	{ID: "security", Title: "Security"},
+	{ID: "support", Title: "Support"},
+	{ID: "ticketing", Title: "Ticketing"},
+	{ID: "version_control", Title: "Version Control"},
+	{ID: "web", Title: "Web"},
+}
+
+// categories are the categories packages can be assigned to, by ID.
+var categories = categoriesByID(defaultCategories)
+
+// CategoryTitles are the titles of the categories by ID, updated when categories are loaded.
+//
+// Deprecated: use GetCategory.
+var CategoryTitles = categoryTitles()
+
+// LoadCategories replaces the default categories with the categories defined in a YAML file,
+// as a list of categories with `id`, `title` and optionally `description`, `icon` and `parent_id`.
+func LoadCategories(path string) (int, error) {
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, errors.Wrapf(err, "reading categories failed (path: %s)", path)
+	}
+
+	var list []Category
+	err = yamlv2.UnmarshalStrict(body, &list)
+	if err != nil {
+		return 0, errors.Wrapf(err, "unmarshaling categories failed (path: %s)", path)
+	}
+
+	err = validateCategories(list)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid categories (path: %s)", path)
+	}
+
+	categories = categoriesByID(list)
+	CategoryTitles = categoryTitles()
+	return len(list), nil
+}
+
+// GetCategory returns the category with the given ID, false if there is no such category.
+func GetCategory(id string) (Category, bool) {
+	c, found := categories[id]
+	return c, found
+}
+
+// CategoryAncestors returns the IDs of the parent categories of a category, starting with its direct parent.
+func CategoryAncestors(id string) []string {
+	var ancestors []string
+	for c, found := categories[id]; found && c.Parent != ""; c, found = categories[c.Parent] {
+		ancestors = append(ancestors, c.Parent)
+	}
+	return ancestors
+}
+
+// IsSubcategory returns true if the category is the given ancestor or one of its subcategories.
+func IsSubcategory(id, ancestor string) bool {
+	if id == ancestor {
+		return true
+	}
+	for _, c := range CategoryAncestors(id) {
+		if c == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+func validateCategories(list []Category) error {
+	if len(list) == 0 {
+		return errors.New("no categories defined")
+	}
+
+	var errs multierror.Errors
+	defined := map[string]bool{}
+	for i, c := range list {
+		switch {
+		case c.ID == "":
+			errs = append(errs, fmt.Errorf("category [%d]: id is missing", i))
+			continue
+		case !categoryIDPattern.MatchString(c.ID):
+			errs = append(errs, fmt.Errorf("category %s: invalid id, expected lowercase letters, digits and underscores", c.ID))
+		case defined[c.ID]:
+			errs = append(errs, fmt.Errorf("category %s: category is defined more than once", c.ID))
+		}
+		defined[c.ID] = true
+
+		if c.Title == "" {
+			errs = append(errs, fmt.Errorf("category %s: title is missing", c.ID))
+		}
+	}
+
+	byID := categoriesByID(list)
+	for _, c := range list {
+		if c.Parent == "" {
+			continue
+		}
+		if _, found := byID[c.Parent]; !found {
+			errs = append(errs, fmt.Errorf("category %s: parent category %s not found", c.ID, c.Parent))
+			continue
+		}
+
+		// Following the parents of a category must end in a top-level category
+		visited := map[string]bool{c.ID: true}
+		for p := byID[c.Parent]; p.Parent != ""; p = byID[p.Parent] {
+			if visited[p.ID] {
+				errs = append(errs, fmt.Errorf("category %s: cycle in parent categories", c.ID))
+				break
+			}
+			visited[p.ID] = true
+		}
+	}
+	return errs.Err()
+}
+
+func categoriesByID(list []Category) map[string]Category {
+	result := map[string]Category{}
+	for _, c := range list {
+		result[c.ID] = c
+	}
+	return result
+}
+
+// categoryTitles returns the titles of the current categories by ID.
+func categoryTitles() map[string]string {
+	titles := map[string]string{}
+	for id := range categories {
+		c, _ := GetCategory(id)
+		titles[id] = c.Title
+	}
+	return titles
+}
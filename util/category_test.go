@@ -0,0 +1,98 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadCategories(t *testing.T) {
+	defer func(c map[string]Category, titles map[string]string) {
+		categories = c
+		CategoryTitles = titles
+	}(categories, CategoryTitles)
+	assert.Equal(t, "OS & System", CategoryTitles["os_system"])
+
+	aws, found := GetCategory("aws")
+	assert.True(t, found)
+	assert.Equal(t, "AWS", aws.Title)
+
+	count, err := LoadCategories("../testdata/categories.yml")
+	require.NoError(t, err)
+	assert.Equal(t, 5, count)
+
+	_, found = GetCategory("azure")
+	assert.False(t, found)
+	internal, found := GetCategory("internal_tools")
+	assert.True(t, found)
+	assert.Equal(t, Category{ID: "internal_tools", Title: "Internal Tools", Description: "Integrations for tools developed in-house."}, internal)
+	cloud, _ := GetCategory("cloud")
+	assert.Equal(t, "logoCloud", cloud.Icon)
+
+	assert.Equal(t, "Internal Tools", CategoryTitles["internal_tools"])
+	assert.NotContains(t, CategoryTitles, "os_system")
+}
+
+func TestLoadInvalidCategories(t *testing.T) {
+	defer func(c map[string]Category) { categories = c }(categories)
+
+	tests := []struct {
+		title string
+		path  string
+		err   string
+	}{
+		{"empty", "../testdata/categories-invalid/empty.yml", "no categories defined"},
+		{"unknown key", "../testdata/categories-invalid/unknown-key.yml", "field color not found"},
+		{"invalid", "../testdata/categories-invalid/invalid.yml", "4 errors: " +
+			"category Web Services: invalid id, expected lowercase letters, digits and underscores; " +
+			"category web: category is defined more than once; " +
+			"category [3]: id is missing; " +
+			"category no_title: title is missing"},
+		{"parents", "../testdata/categories-invalid/parents.yml", "5 errors: " +
+			"category cloud: parent category infrastructure not found; " +
+			"category aws: cycle in parent categories; " +
+			"category ec2: cycle in parent categories; " +
+			"category self: cycle in parent categories; " +
+			"category s3: cycle in parent categories"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			_, err := LoadCategories(tt.path)
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.err)
+			}
+			_, found := GetCategory("aws")
+			assert.True(t, found, "categories must not change if loading fails")
+		})
+	}
+}
+
+func TestCategoryAncestors(t *testing.T) {
+	defer func(c map[string]Category, titles map[string]string) {
+		categories = c
+		CategoryTitles = titles
+	}(categories, CategoryTitles)
+
+	_, err := LoadCategories("../testdata/categories.yml")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"aws", "cloud"}, CategoryAncestors("ec2"))
+	assert.Empty(t, CategoryAncestors("cloud"))
+	assert.Empty(t, CategoryAncestors("missing"))
+
+	assert.True(t, IsSubcategory("ec2", "cloud"))
+	assert.True(t, IsSubcategory("cloud", "cloud"))
+	assert.False(t, IsSubcategory("cloud", "ec2"))
+	assert.False(t, IsSubcategory("web", "cloud"))
+
+	p := Package{Categories: []string{"web", "ec2"}}
+	assert.True(t, p.HasCategoryOrSubcategory("aws"))
+	assert.False(t, p.HasCategory("aws"))
+	assert.False(t, p.HasCategoryOrSubcategory("internal_tools"))
+}
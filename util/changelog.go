@@ -0,0 +1,140 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package util
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/pkg/errors"
+	yamlv2 "gopkg.in/yaml.v2"
+)
+
+const (
+	ChangelogFile = "changelog.yml"
+
+	ChangeTypeAdded          = "added"
+	ChangeTypeEnhancement    = "enhancement"
+	ChangeTypeBugfix         = "bugfix"
+	ChangeTypeDeprecated     = "deprecated"
+	ChangeTypeBreakingChange = "breaking-change"
+	ChangeTypeKnownIssue     = "known-issue"
+)
+
+var changeTypes = map[string]interface{}{
+	ChangeTypeAdded:          nil,
+	ChangeTypeEnhancement:    nil,
+	ChangeTypeBugfix:         nil,
+	ChangeTypeDeprecated:     nil,
+	ChangeTypeBreakingChange: nil,
+	ChangeTypeKnownIssue:     nil,
+}
+
+// ChangelogRelease contains all the changes of a single package version.
+type ChangelogRelease struct {
+	Version string   `json:"version" yaml:"version"`
+	Changes []Change `json:"changes" yaml:"changes"`
+
+	versionSemVer *semver.Version
+}
+
+type Change struct {
+	Description string `json:"description" yaml:"description"`
+	Type        string `json:"type" yaml:"type"`
+	Link        string `json:"link,omitempty" yaml:"link,omitempty"`
+}
+
+// loadChangelog reads the optional changelog.yml file of the package.
+func (p *Package) loadChangelog() error {
+	changelogPath := filepath.Join(p.BasePath, ChangelogFile)
+	body, err := ioutil.ReadFile(changelogPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "reading changelog file failed (path: %s)", changelogPath)
+	}
+
+	var releases []ChangelogRelease
+	err = yamlv2.UnmarshalStrict(body, &releases)
+	if err != nil {
+		return errors.Wrapf(err, "unmarshaling changelog file failed (path: %s)", changelogPath)
+	}
+
+	versions := map[string]bool{}
+	for i, r := range releases {
+		releases[i].versionSemVer, err = semver.StrictNewVersion(r.Version)
+		if err != nil {
+			return errors.Wrapf(err, "invalid version in changelog: %s", r.Version)
+		}
+
+		if versions[r.Version] {
+			return fmt.Errorf("duplicate version in changelog: %s", r.Version)
+		}
+		versions[r.Version] = true
+
+		if len(r.Changes) == 0 {
+			return fmt.Errorf("no changes defined for version %s in changelog", r.Version)
+		}
+
+		for _, c := range r.Changes {
+			if c.Description == "" {
+				return fmt.Errorf("no description set for change in version %s in changelog", r.Version)
+			}
+
+			if _, ok := changeTypes[c.Type]; !ok {
+				return fmt.Errorf("invalid change type in version %s in changelog: %s", r.Version, c.Type)
+			}
+		}
+	}
+
+	p.Changelog = releases
+	return nil
+}
+
+// Changelog aggregates the changelogs of all versions of the package with the given name and returns
+// the releases newer than from and up to and including to, newest first. Nil versions are not limiting the range.
+// If the same release is described by multiple package versions, the description in the newest package wins.
+func (packages Packages) Changelog(name string, from, to *semver.Version) []ChangelogRelease {
+	var versions Packages
+	for _, p := range packages {
+		if p.Name == name {
+			versions = append(versions, p)
+		}
+	}
+
+	// Newest packages first, the first package path containing a version wins
+	sort.SliceStable(versions, func(i, j int) bool {
+		return !versions[j].IsNewerOrEqual(versions[i])
+	})
+
+	var releases []ChangelogRelease
+	seen := map[string]bool{}
+	for _, p := range versions {
+		for _, r := range p.Changelog {
+			if seen[r.Version] {
+				continue
+			}
+			seen[r.Version] = true
+
+			if from != nil && !r.versionSemVer.GreaterThan(from) {
+				continue
+			}
+			if to != nil && r.versionSemVer.GreaterThan(to) {
+				continue
+			}
+			releases = append(releases, r)
+		}
+	}
+
+	sort.SliceStable(releases, func(i, j int) bool {
+		return releases[i].versionSemVer.GreaterThan(releases[j].versionSemVer)
+	})
+	return releases
+}
@@ -0,0 +1,39 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var changelogTests = []struct {
+	basePath    string
+	valid       bool
+	description string
+}{
+	{"../testdata/changelog/valid", true, "valid"},
+	{"../testdata/changelog/invalid-version", false, "invalid version"},
+	{"../testdata/changelog/invalid-type", false, "invalid type"},
+	{"../testdata/changelog/missing-description", false, "missing description"},
+	{"../testdata/changelog/no-changes", false, "no changes"},
+	{"../testdata/changelog/duplicate-version", false, "duplicate version"},
+}
+
+func TestLoadChangelog(t *testing.T) {
+	for _, tt := range changelogTests {
+		t.Run(tt.description, func(t *testing.T) {
+			p := Package{BasePath: tt.basePath}
+			err := p.loadChangelog()
+			if tt.valid {
+				assert.NoError(t, err)
+				assert.Len(t, p.Changelog, 1)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
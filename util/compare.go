@@ -0,0 +1,431 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+// PackageComparison describes the structural differences between two versions of a package.
+type PackageComparison struct {
+	From            string            `json:"from"`
+	To              string            `json:"to"`
+	Files           ListComparison    `json:"files"`
+	DataStreams     ListComparison    `json:"data_streams"`
+	Inputs          ListComparison    `json:"inputs"`
+	Fields          []FieldChange     `json:"fields,omitempty"`
+	Vars            []VariableChange  `json:"vars,omitempty"`
+	IngestPipelines []PipelineChange  `json:"ingest_pipelines,omitempty"`
+	Conditions      *ConditionsChange `json:"conditions,omitempty"`
+}
+
+// ListComparison contains the entries only existing in one of the versions, and the ones which changed.
+type ListComparison struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	Changed []string `json:"changed,omitempty"`
+}
+
+type FieldChange struct {
+	DataStream string `json:"data_stream"`
+	Name       string `json:"name"`
+	Change     string `json:"change"`
+	FromType   string `json:"from_type,omitempty"`
+	ToType     string `json:"to_type,omitempty"`
+}
+
+type VariableChange struct {
+	// Scope is where the variable is defined, for example `package`,
+	// `policy_template.{name}.input.{type}` or `data_stream.{name}.stream.{index}.{input}`
+	Scope  string `json:"scope"`
+	Name   string `json:"name"`
+	Change string `json:"change"`
+	// Properties lists the properties which changed, only set for changed variables
+	Properties []string  `json:"properties,omitempty"`
+	From       *Variable `json:"from,omitempty"`
+	To         *Variable `json:"to,omitempty"`
+}
+
+type PipelineChange struct {
+	DataStream string `json:"data_stream"`
+	Name       string `json:"name"`
+	Change     string `json:"change"`
+}
+
+type ConditionsChange struct {
+	FromKibanaVersion string `json:"from_kibana.version"`
+	ToKibanaVersion   string `json:"to_kibana.version"`
+}
+
+// ComparePackages compares two versions of a package.
+func ComparePackages(from, to *Package) (*PackageComparison, error) {
+	c, err := compareStructure(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Files, err = compareFiles(from, to)
+	if err != nil {
+		return nil, errors.Wrap(err, "comparing files failed")
+	}
+
+	fromDataStreams := dataStreamsByPath(from)
+	toDataStreams := dataStreamsByPath(to)
+	for _, path := range sortedKeys(fromDataStreams) {
+		toDataStream, ok := toDataStreams[path]
+		if !ok {
+			continue
+		}
+
+		pipelineChanges, err := comparePipelines(fromDataStreams[path], toDataStream)
+		if err != nil {
+			return nil, errors.Wrapf(err, "comparing ingest pipelines failed (data stream: %s)", path)
+		}
+		c.IngestPipelines = append(c.IngestPipelines, pipelineChanges...)
+	}
+
+	fromKibana, toKibana := kibanaVersionCondition(from), kibanaVersionCondition(to)
+	if fromKibana != toKibana {
+		c.Conditions = &ConditionsChange{
+			FromKibanaVersion: fromKibana,
+			ToKibanaVersion:   toKibana,
+		}
+	}
+	return c, nil
+}
+
+// compareStructure compares data streams, inputs, variables and fields of two versions of a package.
+// Contrary to ComparePackages, the content of the package files is not compared.
+func compareStructure(from, to *Package) (*PackageComparison, error) {
+	c := &PackageComparison{
+		From: from.Version,
+		To:   to.Version,
+	}
+
+	fromDataStreams := dataStreamsByPath(from)
+	toDataStreams := dataStreamsByPath(to)
+	c.DataStreams = compareKeys(fromDataStreams, toDataStreams)
+	c.Inputs = compareKeys(inputsByKey(from), inputsByKey(to))
+
+	c.Vars = append(c.Vars, compareVariables("package", from.Vars, to.Vars)...)
+	c.Vars = append(c.Vars, compareScopedVariables(inputVariables(from), inputVariables(to))...)
+	c.Vars = append(c.Vars, compareScopedVariables(streamVariables(from), streamVariables(to))...)
+
+	for _, path := range sortedKeys(fromDataStreams) {
+		toDataStream, ok := toDataStreams[path]
+		if !ok {
+			continue
+		}
+
+		fieldChanges, err := compareFields(fromDataStreams[path], toDataStream)
+		if err != nil {
+			return nil, errors.Wrapf(err, "comparing fields failed (data stream: %s)", path)
+		}
+		c.Fields = append(c.Fields, fieldChanges...)
+	}
+	return c, nil
+}
+
+// relativeAssets returns the assets of the package relative to the package root.
+func (p *Package) relativeAssets() map[string]interface{} {
+	prefix := p.GetUrlPath() + "/"
+	assets := map[string]interface{}{}
+	for _, a := range p.Assets {
+		assets[strings.TrimPrefix(a, prefix)] = nil
+	}
+	return assets
+}
+
+func compareFiles(from, to *Package) (ListComparison, error) {
+	fromAssets := from.relativeAssets()
+	toAssets := to.relativeAssets()
+
+	c := compareKeys(fromAssets, toAssets)
+	for _, a := range sortedKeys(fromAssets) {
+		if _, ok := toAssets[a]; !ok {
+			continue
+		}
+
+		equal, err := equalFiles(filepath.Join(from.BasePath, a), filepath.Join(to.BasePath, a))
+		if err != nil {
+			return c, err
+		}
+		if !equal {
+			c.Changed = append(c.Changed, a)
+		}
+	}
+	return c, nil
+}
+
+func equalFiles(a, b string) (bool, error) {
+	contentA, err := ioutil.ReadFile(a)
+	if err != nil {
+		return false, errors.Wrapf(err, "reading file failed (path: %s)", a)
+	}
+	contentB, err := ioutil.ReadFile(b)
+	if err != nil {
+		return false, errors.Wrapf(err, "reading file failed (path: %s)", b)
+	}
+	return bytes.Equal(contentA, contentB), nil
+}
+
+func dataStreamsByPath(p *Package) map[string]*DataStream {
+	dataStreams := map[string]*DataStream{}
+	for _, d := range p.DataStreams {
+		dataStreams[d.Path] = d
+	}
+	return dataStreams
+}
+
+func inputsByKey(p *Package) map[string]Input {
+	inputs := map[string]Input{}
+	for _, t := range p.PolicyTemplates {
+		for _, i := range t.Inputs {
+			inputs[inputScope(t, i)] = i
+		}
+	}
+	return inputs
+}
+
+const (
+	inputScopeFormat  = "policy_template.%s.input.%s"
+	streamScopeFormat = "data_stream.%s.stream.%s"
+
+	// streamIndexScopeFormat identifies streams by index, as a data stream can contain multiple
+	// streams for the same input.
+	streamIndexScopeFormat = "data_stream.%s.stream.%d.%s"
+)
+
+func inputScope(t PolicyTemplate, i Input) string {
+	return fmt.Sprintf(inputScopeFormat, t.Name, i.Type)
+}
+
+func inputVariables(p *Package) map[string][]Variable {
+	vars := map[string][]Variable{}
+	for _, t := range p.PolicyTemplates {
+		for _, i := range t.Inputs {
+			vars[inputScope(t, i)] = i.Vars
+		}
+	}
+	return vars
+}
+
+func streamVariables(p *Package) map[string][]Variable {
+	vars := map[string][]Variable{}
+	for _, d := range p.DataStreams {
+		for i, s := range d.Streams {
+			vars[fmt.Sprintf(streamIndexScopeFormat, d.Path, i, s.Input)] = s.Vars
+		}
+	}
+	return vars
+}
+
+func compareScopedVariables(from, to map[string][]Variable) []VariableChange {
+	scopes := map[string]interface{}{}
+	for scope := range from {
+		scopes[scope] = nil
+	}
+	for scope := range to {
+		scopes[scope] = nil
+	}
+
+	var changes []VariableChange
+	for _, scope := range sortedKeys(scopes) {
+		changes = append(changes, compareVariables(scope, from[scope], to[scope])...)
+	}
+	return changes
+}
+
+func compareVariables(scope string, from, to []Variable) []VariableChange {
+	fromVars := map[string]Variable{}
+	for _, v := range from {
+		fromVars[v.Name] = v
+	}
+	toVars := map[string]Variable{}
+	for _, v := range to {
+		toVars[v.Name] = v
+	}
+
+	var changes []VariableChange
+	for _, name := range sortedKeys(fromVars) {
+		fromVar := fromVars[name]
+		toVar, ok := toVars[name]
+		if !ok {
+			changes = append(changes, VariableChange{Scope: scope, Name: name, Change: ChangeRemoved, From: &fromVar})
+			continue
+		}
+
+		var properties []string
+		if fromVar.Type != toVar.Type {
+			properties = append(properties, "type")
+		}
+		if fromVar.Required != toVar.Required {
+			properties = append(properties, "required")
+		}
+		if !reflect.DeepEqual(fromVar.Default, toVar.Default) {
+			properties = append(properties, "default")
+		}
+		if len(properties) > 0 {
+			changes = append(changes, VariableChange{Scope: scope, Name: name, Change: ChangeChanged, Properties: properties, From: &fromVar, To: &toVar})
+		}
+	}
+
+	for _, name := range sortedKeys(toVars) {
+		if _, ok := fromVars[name]; ok {
+			continue
+		}
+		toVar := toVars[name]
+		changes = append(changes, VariableChange{Scope: scope, Name: name, Change: ChangeAdded, To: &toVar})
+	}
+	return changes
+}
+
+func compareFields(from, to *DataStream) ([]FieldChange, error) {
+	fromFields, err := fieldTypes(from)
+	if err != nil {
+		return nil, err
+	}
+	toFields, err := fieldTypes(to)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []FieldChange
+	for _, name := range sortedKeys(fromFields) {
+		toType, ok := toFields[name]
+		if !ok {
+			changes = append(changes, FieldChange{DataStream: from.Path, Name: name, Change: ChangeRemoved, FromType: fromFields[name]})
+			continue
+		}
+		if fromFields[name] != toType {
+			changes = append(changes, FieldChange{DataStream: from.Path, Name: name, Change: ChangeChanged, FromType: fromFields[name], ToType: toType})
+		}
+	}
+	for _, name := range sortedKeys(toFields) {
+		if _, ok := fromFields[name]; !ok {
+			changes = append(changes, FieldChange{DataStream: from.Path, Name: name, Change: ChangeAdded, ToType: toFields[name]})
+		}
+	}
+	return changes, nil
+}
+
+func fieldTypes(d *DataStream) (map[string]string, error) {
+	fields, err := d.LoadFields()
+	if err != nil {
+		return nil, err
+	}
+
+	types := map[string]string{}
+	for _, f := range fields {
+		types[f.Name] = f.Type
+	}
+	return types, nil
+}
+
+func comparePipelines(from, to *DataStream) ([]PipelineChange, error) {
+	fromPipelines, err := pipelineFiles(from)
+	if err != nil {
+		return nil, err
+	}
+	toPipelines, err := pipelineFiles(to)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []PipelineChange
+	for _, name := range sortedKeys(fromPipelines) {
+		toPath, ok := toPipelines[name]
+		if !ok {
+			changes = append(changes, PipelineChange{DataStream: from.Path, Name: name, Change: ChangeRemoved})
+			continue
+		}
+
+		equal, err := equalFiles(fromPipelines[name], toPath)
+		if err != nil {
+			return nil, err
+		}
+		if !equal {
+			changes = append(changes, PipelineChange{DataStream: from.Path, Name: name, Change: ChangeChanged})
+		}
+	}
+	for _, name := range sortedKeys(toPipelines) {
+		if _, ok := fromPipelines[name]; !ok {
+			changes = append(changes, PipelineChange{DataStream: from.Path, Name: name, Change: ChangeAdded})
+		}
+	}
+	return changes, nil
+}
+
+// pipelineFiles returns the paths of the ingest pipeline files of the data stream, by file name.
+func pipelineFiles(d *DataStream) (map[string]string, error) {
+	paths, err := filepath.Glob(filepath.Join(d.BasePath, "elasticsearch", DirIngestPipeline, "*"))
+	if err != nil {
+		return nil, err
+	}
+
+	pipelines := map[string]string{}
+	for _, path := range paths {
+		pipelines[filepath.Base(path)] = path
+	}
+	return pipelines, nil
+}
+
+func kibanaVersionCondition(p *Package) string {
+	if p.Conditions == nil {
+		return ""
+	}
+	return p.Conditions.KibanaVersion
+}
+
+// compareKeys compares the keys of two maps with string keys.
+func compareKeys(from, to interface{}) ListComparison {
+	fromKeys := sortedKeys(from)
+	toKeys := sortedKeys(to)
+
+	var c ListComparison
+	c.Removed = missingKeys(fromKeys, toKeys)
+	c.Added = missingKeys(toKeys, fromKeys)
+	return c
+}
+
+// missingKeys returns the keys of a which are not in b.
+func missingKeys(a, b []string) []string {
+	existing := map[string]bool{}
+	for _, k := range b {
+		existing[k] = true
+	}
+
+	var missing []string
+	for _, k := range a {
+		if !existing[k] {
+			missing = append(missing, k)
+		}
+	}
+	return missing
+}
+
+// sortedKeys returns the sorted keys of a map with string keys.
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	for _, k := range reflect.ValueOf(m).MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
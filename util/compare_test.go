@@ -0,0 +1,30 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamVariablesSameInput(t *testing.T) {
+	p := &Package{
+		DataStreams: []*DataStream{
+			{
+				Path: "log",
+				Streams: []Stream{
+					{Input: "logfile", Title: "Access logs", Vars: []Variable{{Name: "paths", Type: "text"}}},
+					{Input: "logfile", Title: "Error logs", Vars: []Variable{{Name: "tags", Type: "text"}}},
+				},
+			},
+		},
+	}
+
+	assert.Equal(t, map[string][]Variable{
+		"data_stream.log.stream.0.logfile": {{Name: "paths", Type: "text"}},
+		"data_stream.log.stream.1.logfile": {{Name: "tags", Type: "text"}},
+	}, streamVariables(p))
+}
@@ -5,15 +5,12 @@
 package util
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/pkg/errors"
-	yamlv2 "gopkg.in/yaml.v2"
 
 	ucfg "github.com/elastic/go-ucfg"
 	"github.com/elastic/go-ucfg/yaml"
@@ -96,11 +93,6 @@ type Elasticsearch struct {
 	IngestPipelineName    string                 `config:"ingest_pipeline.name,omitempty" json:"ingest_pipeline.name,omitempty" yaml:"ingest_pipeline.name,omitempty"`
 }
 
-type fieldEntry struct {
-	name  string
-	aType string
-}
-
 func NewDataStream(basePath string, p *Package) (*DataStream, error) {
 	// Check if manifest exists
 	manifestPath := filepath.Join(basePath, "manifest.yml")
@@ -144,9 +136,8 @@ func NewDataStream(basePath string, p *Package) (*DataStream, error) {
 			d.Streams[i].Enabled = &trueValue
 		}
 
-		// TODO: validate that the template path actually exists
 		if d.Streams[i].TemplatePath == "" {
-			d.Streams[i].TemplatePath = "stream.yml.hbs"
+			d.Streams[i].TemplatePath = DefaultStreamTemplatePath
 		}
 	}
 
@@ -236,10 +227,27 @@ func (d *DataStream) Validate() error {
 		}
 	}
 
-	err := d.validateRequiredFields()
+	err := d.validateIngestPipelines()
+	if err != nil {
+		return errors.Wrap(err, "validating ingest pipelines failed")
+	}
+
+	for _, s := range d.Streams {
+		err = validateVariableDefinitions(s.Vars)
+		if err != nil {
+			return errors.Wrapf(err, "invalid variables of stream (input: %s)", s.Input)
+		}
+	}
+
+	err = d.validateRequiredFields()
 	if err != nil {
 		return errors.Wrap(err, "validating required fields failed")
 	}
+
+	err = d.validateFields()
+	if err != nil {
+		return errors.Wrap(err, "validating fields failed")
+	}
 	return nil
 }
 
@@ -249,172 +257,51 @@ func (d *DataStream) validType() bool {
 }
 
 func validateIngestPipelineFile(pipelinePath string) error {
-	f, err := ioutil.ReadFile(pipelinePath)
-	if err != nil {
-		return errors.Wrapf(err, "reading ingest pipeline file failed (path: %s)", pipelinePath)
-	}
-
-	ext := filepath.Ext(pipelinePath)
-	var m map[string]interface{}
-	switch ext {
-	case ".json":
-		err = json.Unmarshal(f, &m)
-	case ".yml":
-		err = yamlv2.Unmarshal(f, &m)
-	default:
-		return fmt.Errorf("unsupported pipeline extension (path: %s, ext: %s)", pipelinePath, ext)
-	}
+	_, err := readIngestPipelineFile(pipelinePath)
 	return err
 }
 
 // validateRequiredFields method loads fields from all files and checks if required fields are present.
 func (d *DataStream) validateRequiredFields() error {
-	fieldsDirPath := filepath.Join(d.BasePath, "fields")
-
-	// Collect fields from all files
-	var allFields []MapStr
-	err := filepath.Walk(fieldsDirPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		relativePath, err := filepath.Rel(fieldsDirPath, path)
-		if err != nil {
-			return errors.Wrapf(err, "cannot find relative path (fieldsDirPath: %s, path: %s)", fieldsDirPath, path)
-		}
-
-		if relativePath == "." {
-			return nil
-		}
-
-		body, err := ioutil.ReadFile(path)
-		if err != nil {
-			return errors.Wrapf(err, "reading file failed (path: %s)", path)
-		}
-
-		var m []MapStr
-		err = yamlv2.Unmarshal(body, &m)
-		if err != nil {
-			return errors.Wrapf(err, "unmarshaling file failed (path: %s)", path)
-		}
-
-		allFields = append(allFields, m...)
-		return nil
-	})
+	fields, err := d.LoadFields()
 	if err != nil {
-		return errors.Wrapf(err, "walking through fields files failed")
-	}
-
-	// Flatten all fields
-	for i, fields := range allFields {
-		allFields[i] = fields.Flatten()
+		return err
 	}
 
 	// Verify required keys
-	err = requireField(allFields, "data_stream.type", "constant_keyword", err)
-	err = requireField(allFields, "data_stream.dataset", "constant_keyword", err)
-	err = requireField(allFields, "data_stream.namespace", "constant_keyword", err)
-	err = requireField(allFields, "@timestamp", "date", err)
+	err = requireField(fields, "data_stream.type", "constant_keyword", err)
+	err = requireField(fields, "data_stream.dataset", "constant_keyword", err)
+	err = requireField(fields, "data_stream.namespace", "constant_keyword", err)
+	err = requireField(fields, "@timestamp", "date", err)
 	return err
 }
 
-func requireField(allFields []MapStr, searchedName, expectedType string, validationErr error) error {
+func requireField(fields []Field, searchedName, expectedType string, validationErr error) error {
 	if validationErr != nil {
 		return validationErr
 	}
 
-	f, err := findField(allFields, searchedName)
+	f, err := findField(fields, searchedName)
 	if err != nil {
-		f, err = findFieldSplit(allFields, searchedName)
-		if err != nil {
-			return errors.Wrapf(err, "finding field failed (searchedName: %s)", searchedName)
-		}
+		return errors.Wrapf(err, "finding field failed (searchedName: %s)", searchedName)
 	}
 
-	if f.aType != expectedType {
-		return fmt.Errorf("wrong field type for '%s' (expected: %s, got: %s)", searchedName, expectedType, f.aType)
+	if f.Type != expectedType {
+		return fmt.Errorf("wrong field type for '%s' (expected: %s, got: %s)", searchedName, expectedType, f.Type)
 	}
 	return nil
 }
 
-func findFieldSplit(allFields []MapStr, searchedName string) (*fieldEntry, error) {
-	levels := strings.Split(searchedName, ".")
-	curFields := allFields
-	var err error
-	for _, part := range levels[:len(levels)-1] {
-		curFields, err = getFieldsArray(curFields, part)
-		if err != nil {
-			return nil, errors.Wrapf(err, "failed to find fields array")
-		}
-	}
-	return findField(curFields, levels[len(levels)-1])
-}
-
-func createMapStr(in interface{}) (MapStr, error) {
-	m := make(MapStr)
-	v, ok := in.(map[interface{}]interface{})
-	if !ok {
-		return nil, fmt.Errorf("unable to convert %v to known type", in)
-	}
-	for k, val := range v {
-		m[fmt.Sprintf("%v", k)] = fmt.Sprintf("%v", val)
-	}
-	return m, nil
-}
-
-func getFieldsArray(allFields []MapStr, searchedName string) ([]MapStr, error) {
-	for _, fields := range allFields {
-		name, err := fields.GetValue("name")
-		if err != nil {
-			return nil, errors.Wrapf(err, "cannot get value (key: name)")
-		}
-		if name == searchedName {
-			value, err := fields.GetValue("fields")
-			if err != nil {
-				return nil, errors.Wrapf(err, "cannot get fields")
-			}
-
-			if inArray, ok := value.([]interface{}); ok {
-				m := make([]MapStr, 0, len(inArray))
-				for _, in := range inArray {
-					mapStr, err := createMapStr(in)
-					if err != nil {
-						return nil, errors.Wrapf(err, "cannot create MapStr")
-					}
-					m = append(m, mapStr)
-				}
-				return m, nil
-			}
-			return nil, fmt.Errorf("fields was not []MapStr")
-		}
-	}
-	return nil, fmt.Errorf("field '%s' not found", searchedName)
-}
-
-func findField(allFields []MapStr, searchedName string) (*fieldEntry, error) {
-	for _, fields := range allFields {
-		name, err := fields.GetValue("name")
-		if err != nil {
-			return nil, errors.Wrapf(err, "cannot get value (key: name)")
-		}
-
-		if name != searchedName {
+func findField(fields []Field, searchedName string) (*Field, error) {
+	for i, f := range fields {
+		if f.Name != searchedName {
 			continue
 		}
 
-		aType, err := fields.GetValue("type")
-		if err != nil {
-			return nil, errors.Wrapf(err, "cannot get value (key: type)")
-		}
-
-		if aType == "" {
+		if f.Type == "" {
 			return nil, fmt.Errorf("field '%s' found, but type is undefined", searchedName)
 		}
-
-		return &fieldEntry{
-			name:  name.(string),
-			aType: aType.(string),
-		}, nil
+		return &fields[i], nil
 	}
 	return nil, fmt.Errorf("field '%s' not found", searchedName)
 }
@@ -0,0 +1,248 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/joeshaw/multierror"
+	"github.com/pkg/errors"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+const DirDocs = "docs"
+
+// markdown renders Markdown in GitHub flavour. Raw HTML and links with dangerous URLs like `javascript:`
+// are omitted by the renderer.
+var markdown = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+)
+
+var (
+	// docFieldsPlaceholder is replaced by the table of fields of a data stream, e.g. `{{fields "access"}}`.
+	docFieldsPlaceholder = regexp.MustCompile(`\{\{\s*fields\s+"([^"]*)"\s*\}\}`)
+	// docExportedFieldsPlaceholder is replaced by the tables of fields of all data streams.
+	docExportedFieldsPlaceholder = regexp.MustCompile(`\{\{\s*exported_fields\s*\}\}`)
+)
+
+// docHeading is a heading of a document, used for the table of contents.
+type docHeading struct {
+	level int
+	id    string
+	text  string
+}
+
+// HasDocPlaceholders returns true if the Markdown source contains placeholders replaced by LoadDoc.
+func HasDocPlaceholders(source []byte) bool {
+	return docFieldsPlaceholder.Match(source) || docExportedFieldsPlaceholder.Match(source)
+}
+
+// LoadDoc loads a Markdown file of the docs directory of the package and replaces the placeholders for fields
+// with tables of the fields of the data streams.
+func (p *Package) LoadDoc(name string) ([]byte, error) {
+	source, err := ioutil.ReadFile(filepath.Join(p.BasePath, DirDocs, name))
+	if err != nil {
+		return nil, err
+	}
+
+	var errs multierror.Errors
+	source = docFieldsPlaceholder.ReplaceAllFunc(source, func(placeholder []byte) []byte {
+		dataStream := string(docFieldsPlaceholder.FindSubmatch(placeholder)[1])
+		d := p.GetDataStream(dataStream)
+		if d == nil {
+			errs = append(errs, fmt.Errorf("data stream \"%s\" does not exist", dataStream))
+			return placeholder
+		}
+
+		table, err := fieldsTable(d)
+		if err != nil {
+			errs = append(errs, err)
+			return placeholder
+		}
+		return bytes.TrimSuffix(table, []byte("\n"))
+	})
+	source = docExportedFieldsPlaceholder.ReplaceAllFunc(source, func(placeholder []byte) []byte {
+		var buf bytes.Buffer
+		for _, d := range p.DataStreams {
+			table, err := fieldsTable(d)
+			if err != nil {
+				errs = append(errs, err)
+				return placeholder
+			}
+			if table == nil {
+				continue
+			}
+			fmt.Fprintf(&buf, "**%s**\n\n", d.Path)
+			buf.Write(table)
+			buf.WriteString("\n")
+		}
+		return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	})
+	if err := errs.Err(); err != nil {
+		return nil, errors.Wrapf(err, "replacing placeholders in %s failed", name)
+	}
+	return source, nil
+}
+
+// fieldsTable renders the fields of the data stream as Markdown table, nil if there are no fields.
+func fieldsTable(d *DataStream) ([]byte, error) {
+	fields, err := d.LoadFields()
+	if err != nil {
+		return nil, errors.Wrapf(err, "loading fields of data stream %s failed", d.Path)
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("| Field | Description | Type |\n|---|---|---|\n")
+	for _, f := range fields {
+		fmt.Fprintf(&buf, "| %s | %s | %s |\n", escapeTableCell(f.Name), escapeTableCell(f.Description), escapeTableCell(f.Type))
+	}
+	return buf.Bytes(), nil
+}
+
+func escapeTableCell(value string) string {
+	value = strings.Join(strings.Fields(value), " ")
+	return strings.Replace(value, "|", "\\|", -1)
+}
+
+// validateDocs checks that the placeholders in the docs of the package reference existing data streams.
+func (p *Package) validateDocs() error {
+	paths, err := filepath.Glob(filepath.Join(p.BasePath, DirDocs, "*.md"))
+	if err != nil {
+		return err
+	}
+
+	var errs multierror.Errors
+	for _, path := range paths {
+		source, err := ioutil.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "reading docs failed (path: %s)", path)
+		}
+
+		for _, matches := range docFieldsPlaceholder.FindAllSubmatch(source, -1) {
+			if p.GetDataStream(string(matches[1])) == nil {
+				errs = append(errs, fmt.Errorf("file %s/%s: unknown data stream \"%s\" in %s", DirDocs, filepath.Base(path), matches[1], matches[0]))
+			}
+		}
+	}
+	return errs.Err()
+}
+
+// RenderDoc renders a Markdown file of the docs directory of the package to sanitized HTML. Relative links and
+// images are rewritten to absolute URLs of the package and a table of contents is added at the beginning.
+func (p *Package) RenderDoc(name string) ([]byte, error) {
+	source, err := p.LoadDoc(name)
+	if err != nil {
+		return nil, err
+	}
+
+	packageURL := path.Join(packagePathPrefix, p.GetPath())
+	return renderMarkdown(source, packageURL, path.Join(DirDocs, name))
+}
+
+// renderMarkdown renders the document found in docPath of the package served in packageURL.
+func renderMarkdown(source []byte, packageURL, docPath string) ([]byte, error) {
+	doc := markdown.Parser().Parse(text.NewReader(source))
+
+	var headings []docHeading
+	err := ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		switch n := node.(type) {
+		case *ast.Heading:
+			id, _ := n.AttributeString("id")
+			idBytes, _ := id.([]byte)
+			headings = append(headings, docHeading{level: n.Level, id: string(idBytes), text: string(n.Text(source))})
+		case *ast.Link:
+			n.Destination = []byte(resolveDocURL(string(n.Destination), packageURL, docPath))
+		case *ast.Image:
+			n.Destination = []byte(resolveDocURL(string(n.Destination), packageURL, docPath))
+		}
+		return ast.WalkContinue, nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "walking document failed")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(renderTableOfContents(headings))
+	err = markdown.Renderer().Render(&buf, source, doc)
+	if err != nil {
+		return nil, errors.Wrap(err, "rendering document failed")
+	}
+	return buf.Bytes(), nil
+}
+
+// resolveDocURL rewrites relative URLs to absolute URLs of the package. Paths starting with `/` are relative
+// to the root of the package, other paths to the directory of the document. URLs with a scheme or a host and
+// links to anchors are kept.
+func resolveDocURL(dest, packageURL, docPath string) string {
+	u, err := url.Parse(dest)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" {
+		return dest
+	}
+
+	// Paths are cleaned as rooted paths, so they cannot point outside of the package
+	if strings.HasPrefix(u.Path, "/") {
+		u.Path = path.Join(packageURL, path.Clean(u.Path))
+	} else {
+		u.Path = path.Join(packageURL, path.Join("/", path.Dir(docPath), u.Path))
+	}
+	return u.String()
+}
+
+// renderTableOfContents renders nested lists with links to the headings.
+func renderTableOfContents(headings []docHeading) string {
+	if len(headings) == 0 {
+		return ""
+	}
+
+	var buf strings.Builder
+	buf.WriteString("<nav class=\"toc\">\n<ul>\n")
+	levels := []int{headings[0].level}
+	for i, h := range headings {
+		if i > 0 {
+			closed := false
+			for len(levels) > 1 && h.level < levels[len(levels)-1] {
+				buf.WriteString("</li>\n</ul>\n")
+				levels = levels[:len(levels)-1]
+				closed = true
+			}
+			if h.level > levels[len(levels)-1] {
+				// Lists of skipped levels are opened after the closed lists of deeper levels
+				if !closed {
+					buf.WriteString("\n")
+				}
+				buf.WriteString("<ul>\n")
+				levels = append(levels, h.level)
+			} else {
+				buf.WriteString("</li>\n")
+			}
+		}
+		fmt.Fprintf(&buf, "<li><a href=\"#%s\">%s</a>", html.EscapeString(h.id), html.EscapeString(h.text))
+	}
+	for range levels {
+		buf.WriteString("</li>\n</ul>\n")
+	}
+	buf.WriteString("</nav>\n")
+	return buf.String()
+}
@@ -0,0 +1,104 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveDocURL(t *testing.T) {
+	tests := []struct {
+		dest     string
+		expected string
+	}{
+		{"https://www.elastic.co/guide", "https://www.elastic.co/guide"},
+		{"//www.elastic.co/guide", "//www.elastic.co/guide"},
+		{"mailto:info@elastic.co", "mailto:info@elastic.co"},
+		{"#usage", "#usage"},
+		{"../img/overview.png", "/package/nginx/1.0.0/img/overview.png"},
+		{"access.md#fields", "/package/nginx/1.0.0/docs/access.md#fields"},
+		{"/img/overview.png?raw=true", "/package/nginx/1.0.0/img/overview.png?raw=true"},
+		{"../../../../etc/passwd", "/package/nginx/1.0.0/etc/passwd"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dest, func(t *testing.T) {
+			assert.Equal(t, tt.expected, resolveDocURL(tt.dest, "/package/nginx/1.0.0", "docs/README.md"))
+		})
+	}
+}
+
+func TestRenderMarkdownSanitized(t *testing.T) {
+	source := `# Nginx
+
+<script>alert(document.cookie)</script>
+
+Click [here](javascript:alert(1)) or <a href="#" onclick="alert(1)">here</a>.
+`
+	rendered, err := renderMarkdown([]byte(source), "/package/nginx/1.0.0", "docs/README.md")
+	require.NoError(t, err)
+
+	assert.NotContains(t, string(rendered), "<script")
+	assert.NotContains(t, string(rendered), "javascript:")
+	assert.NotContains(t, string(rendered), "onclick")
+	assert.Contains(t, string(rendered), `<h1 id="nginx">Nginx</h1>`)
+}
+
+func TestRenderTableOfContents(t *testing.T) {
+	assert.Equal(t, "", renderTableOfContents(nil))
+
+	toc := renderTableOfContents([]docHeading{
+		{level: 1, id: "nginx", text: "Nginx"},
+		{level: 2, id: "logs", text: "Logs"},
+		{level: 3, id: "access", text: "Access <logs>"},
+		{level: 2, id: "metrics", text: "Metrics"},
+	})
+	assert.Equal(t, `<nav class="toc">
+<ul>
+<li><a href="#nginx">Nginx</a>
+<ul>
+<li><a href="#logs">Logs</a>
+<ul>
+<li><a href="#access">Access &lt;logs&gt;</a></li>
+</ul>
+</li>
+<li><a href="#metrics">Metrics</a></li>
+</ul>
+</li>
+</ul>
+</nav>
+`, toc)
+}
+
+func TestLoadDoc(t *testing.T) {
+	p, err := NewPackage("../testdata/package/reference/1.0.0")
+	require.NoError(t, err)
+
+	doc, err := p.LoadDoc("README.md")
+	require.NoError(t, err)
+	assert.NotContains(t, string(doc), "{{fields")
+	assert.NotContains(t, string(doc), "{{exported_fields}}")
+	assert.Contains(t, string(doc), "| data_stream.dataset | Data stream dataset. | constant_keyword |\n")
+	assert.Contains(t, string(doc), "**reference**\n\n| Field | Description | Type |\n")
+}
+
+func TestValidateDocs(t *testing.T) {
+	p := Package{BasePath: "../testdata/docs/invalid", DataStreams: []*DataStream{{Path: "access"}}}
+	err := p.validateDocs()
+	assert.EqualError(t, err, `1 error: file docs/README.md: unknown data stream "missing" in {{ fields "missing" }}`)
+}
+
+func TestEscapeTableCell(t *testing.T) {
+	assert.Equal(t, `Status code, like 200 \| 404.`, escapeTableCell("Status code,\n  like 200 | 404.\n"))
+}
+
+func TestHasDocPlaceholders(t *testing.T) {
+	assert.True(t, HasDocPlaceholders([]byte("# Access\n\n{{fields \"access\"}}\n")))
+	assert.True(t, HasDocPlaceholders([]byte("{{ exported_fields }}")))
+	assert.False(t, HasDocPlaceholders([]byte("# Nginx\n\nCollects `{{fields}}` of nginx.")))
+}
@@ -0,0 +1,106 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package util
+
+import (
+	"io/ioutil"
+	"log"
+	"sync"
+
+	"github.com/pkg/errors"
+	yamlv2 "gopkg.in/yaml.v2"
+)
+
+// fieldExternalECS is the value of `external` of fields whose definition is imported from ECS.
+const fieldExternalECS = "ecs"
+
+// ecsFields are the fields of the Elastic Common Schema by dotted name, nil if they are not loaded.
+var ecsFields map[string]fieldDefinition
+
+// ecsFieldsNotLoadedWarning logs only once that fields cannot be imported, as packages are validated on every load.
+var ecsFieldsNotLoadedWarning sync.Once
+
+// LoadECSFields loads the reference of ECS fields from a file in the format of `ecs_flat.yml`, as generated
+// for each ECS version. Fields of packages are checked against these fields and can import their definitions.
+func LoadECSFields(path string) (int, error) {
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, errors.Wrapf(err, "reading ECS fields failed (path: %s)", path)
+	}
+
+	var fields map[string]fieldDefinition
+	err = yamlv2.Unmarshal(body, &fields)
+	if err != nil {
+		return 0, errors.Wrapf(err, "unmarshaling ECS fields failed (path: %s)", path)
+	}
+	if len(fields) == 0 {
+		return 0, errors.Errorf("no ECS fields found (path: %s)", path)
+	}
+
+	ecsFields = fields
+	return len(fields), nil
+}
+
+// withECSDefinition completes the definition of a field imported from ECS, the settings of the
+// package take precedence.
+func (definition fieldDefinition) withECSDefinition(ecsField fieldDefinition) fieldDefinition {
+	if definition.Type == "" {
+		definition.Type = ecsField.Type
+	}
+	if definition.Description == "" {
+		definition.Description = ecsField.Description
+	}
+	if len(definition.MultiFields) == 0 {
+		definition.MultiFields = ecsField.MultiFields
+	}
+	if definition.ObjectType == "" {
+		definition.ObjectType = ecsField.ObjectType
+	}
+	if definition.IgnoreAbove == 0 {
+		definition.IgnoreAbove = ecsField.IgnoreAbove
+	}
+	if definition.ScalingFactor == 0 {
+		definition.ScalingFactor = ecsField.ScalingFactor
+	}
+	if definition.Index == nil {
+		definition.Index = ecsField.Index
+	}
+	if definition.DocValues == nil {
+		definition.DocValues = ecsField.DocValues
+	}
+	return definition
+}
+
+func warnECSFieldsNotLoaded() {
+	ecsFieldsNotLoadedWarning.Do(func() {
+		log.Println("warning: ECS fields are not loaded, definitions of fields with `external: ecs` are not imported")
+	})
+}
+
+// warnECSConflicts logs a warning for fields of the package not imported from ECS that have the name
+// of an ECS field but a different type.
+func (p *Package) warnECSConflicts() error {
+	for _, d := range p.DataStreams {
+		fields, err := d.LoadFields()
+		if err != nil {
+			return errors.Wrapf(err, "loading fields of data stream %s failed", d.Path)
+		}
+
+		warned := map[string]bool{}
+		for _, f := range fields {
+			ecsField, found := ecsFields[f.Name]
+			if !found || f.External != "" || warned[f.Name] {
+				continue
+			}
+
+			if fieldTypeOrDefault(f.Type) != fieldTypeOrDefault(ecsField.Type) {
+				log.Printf("warning: data stream %s: file %s: field %s: type %s differs from type %s of the ECS field",
+					d.BasePath, f.File, f.Name, fieldTypeOrDefault(f.Type), fieldTypeOrDefault(ecsField.Type))
+				warned[f.Name] = true
+			}
+		}
+	}
+	return nil
+}
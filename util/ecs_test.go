@@ -0,0 +1,112 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package util
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/joeshaw/multierror"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	yamlv2 "gopkg.in/yaml.v2"
+)
+
+func TestLoadECSFields(t *testing.T) {
+	defer func(fields map[string]fieldDefinition) { ecsFields = fields }(ecsFields)
+
+	count, err := LoadECSFields("../ecs/1.8.0/ecs_flat.yml")
+	require.NoError(t, err)
+	assert.Equal(t, 673, count)
+
+	assert.Equal(t, "date", ecsFields["@timestamp"].Type)
+	assert.Equal(t, "keyword", ecsFields["host.name"].Type)
+	assert.Equal(t, 1024, ecsFields["host.name"].IgnoreAbove)
+	assert.Equal(t, []fieldDefinition{{Name: "text", Type: "text"}}, ecsFields["user.name"].MultiFields)
+
+	_, err = LoadECSFields("../ecs/missing.yml")
+	assert.Error(t, err)
+}
+
+func TestFlattenExternalFieldDefinitions(t *testing.T) {
+	defer func(fields map[string]fieldDefinition) { ecsFields = fields }(ecsFields)
+	ecsFields = map[string]fieldDefinition{
+		"host.name": {Type: "keyword", Description: "Name of the host.", IgnoreAbove: 1024},
+		"user.name": {Type: "keyword", Description: "Short name or login of the user.", MultiFields: []fieldDefinition{{Name: "text", Type: "text"}}},
+	}
+
+	body := `
+- name: host
+  type: group
+  fields:
+    - name: name
+      external: ecs
+- name: user.name
+  external: ecs
+  description: User running the process.
+`
+	var definitions []fieldDefinition
+	require.NoError(t, yamlv2.Unmarshal([]byte(body), &definitions))
+
+	fields := flattenFieldDefinitions("", "fields/ecs.yml", definitions)
+	assert.Equal(t, []Field{
+		{Name: "host.name", Type: "keyword", Description: "Name of the host.", IgnoreAbove: 1024, File: "fields/ecs.yml", External: "ecs"},
+		{Name: "user.name", Type: "keyword", Description: "User running the process.", MultiFields: []MultiField{{Name: "text", Type: "text"}}, File: "fields/ecs.yml", External: "ecs"},
+	}, fields)
+}
+
+func TestValidateECSFields(t *testing.T) {
+	defer func(fields map[string]fieldDefinition) { ecsFields = fields }(ecsFields)
+	ecsFields = map[string]fieldDefinition{
+		"host.name":   {Type: "keyword"},
+		"source.port": {Type: "long"},
+	}
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	d := DataStream{BasePath: "../testdata/fields/ecs"}
+	err := d.validateFields()
+	require.Error(t, err)
+	assert.Equal(t, []string{
+		`file fields/fields.yml: field host.uptime: field not found in ECS`,
+		`file fields/fields.yml: field host.os: field not found in ECS`,
+		`file fields/fields.yml: field host.os: external field cannot have fields`,
+		`file fields/fields.yml: field message: invalid external "beats", expected ecs`,
+	}, errorMessages(err))
+	assert.Empty(t, buf.String(), "warnings are only logged when packages are loaded")
+
+	p := Package{DataStreams: []*DataStream{&d}}
+	require.NoError(t, p.warnECSConflicts())
+	assert.Equal(t, 1, strings.Count(buf.String(), "warning: "))
+	assert.Contains(t, buf.String(), "warning: data stream ../testdata/fields/ecs: file fields/fields.yml: field source.port: type keyword differs from type long of the ECS field")
+
+	// Without ECS fields, fields are not imported and a single warning is logged
+	ecsFields = nil
+	ecsFieldsNotLoadedWarning = sync.Once{}
+	buf.Reset()
+	for i := 0; i < 2; i++ {
+		err = d.validateFields()
+		require.Error(t, err)
+		assert.Equal(t, []string{
+			`file fields/fields.yml: field host.os: external field cannot have fields`,
+			`file fields/fields.yml: field message: invalid external "beats", expected ecs`,
+		}, errorMessages(err))
+	}
+	assert.Equal(t, 1, strings.Count(buf.String(), "warning: ECS fields are not loaded"))
+}
+
+func errorMessages(err error) []string {
+	var messages []string
+	for _, e := range err.(*multierror.MultiError).Errors {
+		messages = append(messages, e.Error())
+	}
+	return messages
+}
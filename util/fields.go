@@ -0,0 +1,341 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package util
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/joeshaw/multierror"
+	"github.com/pkg/errors"
+	yamlv2 "gopkg.in/yaml.v2"
+)
+
+const fieldTypeGroup = "group"
+
+// allowedFieldTypes are the types allowed in fields definitions. Besides `group`, these are the Elasticsearch field
+// types supported by Fleet. Fields without type are keywords.
+var allowedFieldTypes = map[string]bool{
+	fieldTypeGroup:            true,
+	"aggregate_metric_double": true,
+	"alias":                   true,
+	"binary":                  true,
+	"boolean":                 true,
+	"byte":                    true,
+	"completion":              true,
+	"constant_keyword":        true,
+	"date":                    true,
+	"date_nanos":              true,
+	"date_range":              true,
+	"dense_vector":            true,
+	"double":                  true,
+	"double_range":            true,
+	"flattened":               true,
+	"float":                   true,
+	"float_range":             true,
+	"geo_point":               true,
+	"geo_shape":               true,
+	"half_float":              true,
+	"histogram":               true,
+	"integer":                 true,
+	"integer_range":           true,
+	"ip":                      true,
+	"ip_range":                true,
+	"keyword":                 true,
+	"long":                    true,
+	"long_range":              true,
+	"match_only_text":         true,
+	"nested":                  true,
+	"object":                  true,
+	"scaled_float":            true,
+	"search_as_you_type":      true,
+	"short":                   true,
+	"text":                    true,
+	"token_count":             true,
+	"unsigned_long":           true,
+	"version":                 true,
+	"wildcard":                true,
+}
+
+// fieldTypesWithFields are the types of fields that can have child fields.
+var fieldTypesWithFields = map[string]bool{
+	fieldTypeGroup: true,
+	"nested":       true,
+	"object":       true,
+}
+
+// Field is a single field of a data stream, with the name resolved to its dotted form.
+type Field struct {
+	Name        string       `json:"name"`
+	Type        string       `json:"type,omitempty"`
+	Description string       `json:"description,omitempty"`
+	MultiFields []MultiField `json:"multi_fields,omitempty"`
+
+	// Mapping parameters, only set if defined for the field
+	Path          string `json:"path,omitempty"`
+	ObjectType    string `json:"object_type,omitempty"`
+	IgnoreAbove   int    `json:"ignore_above,omitempty"`
+	ScalingFactor int    `json:"scaling_factor,omitempty"`
+	Index         *bool  `json:"index,omitempty"`
+	DocValues     *bool  `json:"doc_values,omitempty"`
+
+	// File is the path of the file defining the field, relative to the data stream
+	File string `json:"file"`
+	// External is the source the definition of the field is imported from, e.g. `ecs`
+	External string `json:"external,omitempty"`
+}
+
+// MultiField is an additional mapping of a field, accessible as {field}.{name}.
+type MultiField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// fieldDefinition is the structure of the fields as defined in the fields/*.yml files.
+type fieldDefinition struct {
+	Name        string            `yaml:"name"`
+	Type        string            `yaml:"type"`
+	Description string            `yaml:"description"`
+	Fields      []fieldDefinition `yaml:"fields"`
+	MultiFields []fieldDefinition `yaml:"multi_fields"`
+
+	Path          string `yaml:"path"`
+	ObjectType    string `yaml:"object_type"`
+	IgnoreAbove   int    `yaml:"ignore_above"`
+	ScalingFactor int    `yaml:"scaling_factor"`
+	Index         *bool  `yaml:"index"`
+	DocValues     *bool  `yaml:"doc_values"`
+
+	External string `yaml:"external"`
+}
+
+// LoadFields loads the fields of all the files in the fields directory of the data stream
+// and resolves nested groups to dotted names. The fields are sorted by name.
+func (d *DataStream) LoadFields() ([]Field, error) {
+	files, err := d.readFieldsFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var fields []Field
+	for _, path := range paths {
+		fields = append(fields, flattenFieldDefinitions("", path, files[path])...)
+	}
+
+	sort.SliceStable(fields, func(i, j int) bool {
+		return fields[i].Name < fields[j].Name
+	})
+	return fields, nil
+}
+
+// readFieldsFiles reads the definitions of all files in the fields directory of the data stream, by path
+// relative to the data stream.
+func (d *DataStream) readFieldsFiles() (map[string][]fieldDefinition, error) {
+	fieldsDirPath := filepath.Join(d.BasePath, "fields")
+
+	_, err := os.Stat(fieldsDirPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "stat fields directory failed (path: %s)", fieldsDirPath)
+	}
+
+	files := map[string][]fieldDefinition{}
+	err = filepath.Walk(fieldsDirPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(d.BasePath, path)
+		if err != nil {
+			return errors.Wrapf(err, "cannot find relative path (basePath: %s, path: %s)", d.BasePath, path)
+		}
+
+		body, err := ioutil.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "reading file failed (path: %s)", path)
+		}
+
+		var definitions []fieldDefinition
+		err = yamlv2.Unmarshal(body, &definitions)
+		if err != nil {
+			return errors.Wrapf(err, "unmarshaling file failed (path: %s)", path)
+		}
+
+		files[filepath.ToSlash(relativePath)] = definitions
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "walking through fields files failed")
+	}
+	return files, nil
+}
+
+// validateFields checks the types of all field definitions of the data stream, that groups have fields and that
+// no field is defined more than once in the same file. Fields can be defined again in other files with the same
+// type, fields defined with different types are reported as conflicts.
+func (d *DataStream) validateFields() error {
+	files, err := d.readFieldsFiles()
+	if err != nil {
+		return err
+	}
+
+	var paths []string
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var errs multierror.Errors
+	defined := map[string]Field{}
+	for _, path := range paths {
+		v := fieldsValidator{file: path}
+		v.validateDefinitions("", files[path])
+		errs = append(errs, v.errs...)
+
+		for _, f := range flattenFieldDefinitions("", path, files[path]) {
+			other, found := defined[f.Name]
+			if !found {
+				defined[f.Name] = f
+				continue
+			}
+
+			if fieldTypeOrDefault(other.Type) != fieldTypeOrDefault(f.Type) {
+				errs = append(errs, fmt.Errorf("file %s: field %s: type %s conflicts with type %s in file %s",
+					path, f.Name, fieldTypeOrDefault(f.Type), fieldTypeOrDefault(other.Type), other.File))
+			} else if other.File == path {
+				errs = append(errs, fmt.Errorf("file %s: field %s: field is already defined in file %s", path, f.Name, other.File))
+			}
+		}
+	}
+
+	return errs.Err()
+}
+
+// fieldTypeOrDefault returns the type of a field, fields without type are keywords.
+func fieldTypeOrDefault(fieldType string) string {
+	if fieldType == "" {
+		return "keyword"
+	}
+	return fieldType
+}
+
+type fieldsValidator struct {
+	file string
+	errs multierror.Errors
+}
+
+func (v *fieldsValidator) errorf(name, format string, args ...interface{}) {
+	v.errs = append(v.errs, fmt.Errorf("file %s: field %s: %s", v.file, name, fmt.Sprintf(format, args...)))
+}
+
+func (v *fieldsValidator) validateDefinitions(prefix string, definitions []fieldDefinition) {
+	for i, definition := range definitions {
+		name := definition.Name
+		if name == "" {
+			v.errorf(fmt.Sprintf("%s[%d]", prefix, i), "name is missing")
+			continue
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+
+		if definition.Type != "" && !allowedFieldTypes[definition.Type] {
+			v.errorf(name, "invalid type \"%s\"", definition.Type)
+		}
+
+		switch definition.External {
+		case "":
+		case fieldExternalECS:
+			if ecsFields == nil {
+				warnECSFieldsNotLoaded()
+			} else if _, found := ecsFields[name]; !found {
+				v.errorf(name, "field not found in ECS")
+			}
+		default:
+			v.errorf(name, "invalid external \"%s\", expected %s", definition.External, fieldExternalECS)
+		}
+
+		switch {
+		case definition.External != "" && len(definition.Fields) > 0:
+			v.errorf(name, "external field cannot have fields")
+		case definition.Type == fieldTypeGroup && len(definition.Fields) == 0:
+			v.errorf(name, "group has no fields")
+		case len(definition.Fields) > 0 && definition.Type != "" && !fieldTypesWithFields[definition.Type]:
+			v.errorf(name, "field of type %s cannot have fields", definition.Type)
+		case definition.Type == "alias" && definition.Path == "":
+			v.errorf(name, "alias requires a path")
+		}
+
+		for _, m := range definition.MultiFields {
+			if m.Name == "" {
+				v.errorf(name, "multi field without name")
+			} else if !allowedFieldTypes[m.Type] || m.Type == fieldTypeGroup {
+				v.errorf(name+"."+m.Name, "invalid type \"%s\" of multi field", m.Type)
+			}
+		}
+
+		v.validateDefinitions(name, definition.Fields)
+	}
+}
+
+func flattenFieldDefinitions(prefix, file string, definitions []fieldDefinition) []Field {
+	var fields []Field
+	for _, definition := range definitions {
+		name := definition.Name
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+
+		// Groups only contribute to the names of their fields, other fields with child fields, like
+		// nested fields, keep their own type
+		if definition.Type == fieldTypeGroup || (definition.Type == "" && len(definition.Fields) > 0) {
+			fields = append(fields, flattenFieldDefinitions(name, file, definition.Fields)...)
+			continue
+		}
+
+		if ecsField, found := ecsFields[name]; found && definition.External == fieldExternalECS {
+			definition = definition.withECSDefinition(ecsField)
+		}
+
+		field := Field{
+			Name:          name,
+			Type:          definition.Type,
+			Description:   strings.TrimSpace(definition.Description),
+			Path:          definition.Path,
+			ObjectType:    definition.ObjectType,
+			IgnoreAbove:   definition.IgnoreAbove,
+			ScalingFactor: definition.ScalingFactor,
+			Index:         definition.Index,
+			DocValues:     definition.DocValues,
+			File:          file,
+			External:      definition.External,
+		}
+		for _, m := range definition.MultiFields {
+			field.MultiFields = append(field.MultiFields, MultiField{
+				Name: m.Name,
+				Type: m.Type,
+			})
+		}
+		fields = append(fields, field)
+		fields = append(fields, flattenFieldDefinitions(name, file, definition.Fields)...)
+	}
+	return fields
+}
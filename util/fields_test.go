@@ -0,0 +1,82 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package util
+
+import (
+	"testing"
+
+	"github.com/joeshaw/multierror"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	yamlv2 "gopkg.in/yaml.v2"
+)
+
+func TestFlattenFieldDefinitions(t *testing.T) {
+	body := `
+- name: nginx
+  type: group
+  fields:
+    - name: access
+      type: group
+      fields:
+        - name: remote_ip
+          type: ip
+          description: >
+            Client IP address.
+        - name: user_agent.original
+          type: keyword
+          multi_fields:
+            - name: text
+              type: text
+- name: message
+  type: text
+- name: process.threads
+  type: nested
+  fields:
+    - name: id
+      type: long
+- name: dns
+  fields:
+    - name: answers
+      type: object
+      fields:
+        - name: ttl
+          type: long
+`
+	var definitions []fieldDefinition
+	require.NoError(t, yamlv2.Unmarshal([]byte(body), &definitions))
+
+	fields := flattenFieldDefinitions("", "fields/fields.yml", definitions)
+	assert.Equal(t, []Field{
+		{Name: "nginx.access.remote_ip", Type: "ip", Description: "Client IP address.", File: "fields/fields.yml"},
+		{Name: "nginx.access.user_agent.original", Type: "keyword", MultiFields: []MultiField{{Name: "text", Type: "text"}}, File: "fields/fields.yml"},
+		{Name: "message", Type: "text", File: "fields/fields.yml"},
+		{Name: "process.threads", Type: "nested", File: "fields/fields.yml"},
+		{Name: "process.threads.id", Type: "long", File: "fields/fields.yml"},
+		{Name: "dns.answers", Type: "object", File: "fields/fields.yml"},
+		{Name: "dns.answers.ttl", Type: "long", File: "fields/fields.yml"},
+	}, fields)
+}
+
+func TestValidateFields(t *testing.T) {
+	d := DataStream{BasePath: "../testdata/fields/invalid"}
+	err := d.validateFields()
+	require.Error(t, err)
+
+	var messages []string
+	for _, e := range err.(*multierror.MultiError).Errors {
+		messages = append(messages, e.Error())
+	}
+	assert.Equal(t, []string{
+		`file fields/fields.yml: field nginx.access.bytes: invalid type "bigint"`,
+		`file fields/fields.yml: field nginx.access.geo: group has no fields`,
+		`file fields/fields.yml: field nginx.access.user_agent: field of type keyword cannot have fields`,
+		`file fields/fields.yml: field nginx.access.source: alias requires a path`,
+		`file fields/fields.yml: field nginx.access.url.text: invalid type "txt" of multi field`,
+		`file fields/fields.yml: field nginx.access[7]: name is missing`,
+		`file fields/fields.yml: field nginx.access.remote_ip: field is already defined in file fields/fields.yml`,
+		`file fields/fields.yml: field message: type keyword conflicts with type text in file fields/base-fields.yml`,
+	}, messages)
+}
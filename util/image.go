@@ -0,0 +1,291 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package util
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image"
+	_ "image/jpeg" // register JPEG decoder
+	_ "image/png"  // register PNG decoder
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/joeshaw/multierror"
+	"github.com/pkg/errors"
+)
+
+const (
+	ImageTypePNG  = "image/png"
+	ImageTypeJPEG = "image/jpeg"
+	ImageTypeSVG  = "image/svg+xml"
+
+	// maxIconFileSize and maxScreenshotFileSize are the file sizes above which a warning is logged,
+	// as these images are loaded by the Kibana UI.
+	maxIconFileSize       = 100 * 1024
+	maxScreenshotFileSize = 2 * 1024 * 1024
+)
+
+// imageMetadata is the metadata read from an image file.
+type imageMetadata struct {
+	contentType string
+	// width and height are zero if the dimensions of an SVG image cannot be determined
+	width  int
+	height int
+}
+
+// validateImages checks the declared metadata of the given images against the files in the package.
+// Images bigger than maxFileSize are reported as warnings.
+func validateImages(basePath string, images []Image, maxFileSize int64) error {
+	for _, i := range images {
+		err := i.validate(basePath, maxFileSize)
+		if err != nil {
+			return errors.Wrapf(err, "invalid image %s", i.Src)
+		}
+	}
+	return nil
+}
+
+// validate checks that the image file exists and that its content type and dimensions match the declared ones.
+// The dimensions of SVG images are not checked.
+func (i Image) validate(basePath string, maxFileSize int64) error {
+	imagePath := filepath.Join(basePath, i.Src)
+	info, err := os.Stat(imagePath)
+	if err != nil {
+		return err
+	}
+	if info.Size() > maxFileSize {
+		log.Printf("warning: image %s is too big (size: %d bytes, recommended maximum: %d bytes)", imagePath, info.Size(), maxFileSize)
+	}
+
+	metadata, err := readImageMetadata(imagePath)
+	if err != nil {
+		return err
+	}
+
+	if i.Type != "" && i.Type != metadata.contentType {
+		return fmt.Errorf("declared type %s doesn't match type of file %s", i.Type, metadata.contentType)
+	}
+
+	if i.Size != "" {
+		width, height, err := parseImageSize(i.Size)
+		if err != nil {
+			return err
+		}
+		// SVG images are scalable, their declared size is the size they are displayed with
+		if metadata.contentType == ImageTypeSVG {
+			return nil
+		}
+		if width != metadata.width || height != metadata.height {
+			return fmt.Errorf("declared size %s doesn't match size of file %dx%d", i.Size, metadata.width, metadata.height)
+		}
+	}
+	return nil
+}
+
+// parseImageSize parses sizes in the format `{width}x{height}`, e.g. `32x32`.
+func parseImageSize(size string) (int, int, error) {
+	parts := strings.Split(size, "x")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid size %s, expected format is {width}x{height}", size)
+	}
+	width, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid width in size %s", size)
+	}
+	height, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid height in size %s", size)
+	}
+	return width, height, nil
+}
+
+// readImageMetadata detects the type and dimensions of PNG, JPEG and SVG files.
+func readImageMetadata(imagePath string) (*imageMetadata, error) {
+	content, err := ioutil.ReadFile(imagePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading image failed (path: %s)", imagePath)
+	}
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(content))
+	if err == nil {
+		switch format {
+		case "png":
+			return &imageMetadata{contentType: ImageTypePNG, width: config.Width, height: config.Height}, nil
+		case "jpeg":
+			return &imageMetadata{contentType: ImageTypeJPEG, width: config.Width, height: config.Height}, nil
+		}
+	}
+
+	metadata, ok := readSVGMetadata(content)
+	if !ok {
+		return nil, errors.New("unsupported image format, expected PNG, JPEG or SVG")
+	}
+	return metadata, nil
+}
+
+// readSVGMetadata reads the dimensions of an SVG image from the width and height attributes of the root
+// element, or from its viewBox if they are not set in pixels.
+func readSVGMetadata(content []byte) (*imageMetadata, bool) {
+	decoder := newSVGDecoder(content)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, false
+		}
+
+		element, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if element.Name.Local != "svg" {
+			return nil, false
+		}
+
+		metadata := &imageMetadata{contentType: ImageTypeSVG}
+		var viewBox string
+		for _, attr := range element.Attr {
+			switch attr.Name.Local {
+			case "width":
+				metadata.width = parseSVGLength(attr.Value)
+			case "height":
+				metadata.height = parseSVGLength(attr.Value)
+			case "viewBox":
+				viewBox = attr.Value
+			}
+		}
+
+		if metadata.width == 0 || metadata.height == 0 {
+			fields := strings.Fields(strings.Replace(viewBox, ",", " ", -1))
+			if len(fields) == 4 {
+				metadata.width = parseSVGLength(fields[2])
+				metadata.height = parseSVGLength(fields[3])
+			}
+		}
+		return metadata, true
+	}
+}
+
+// validateSVGFiles checks that the SVG files of the package contain no scripts. These files are served
+// by the registry and could otherwise run code in its origin.
+func (p *Package) validateSVGFiles() error {
+	var errs multierror.Errors
+	err := filepath.Walk(p.BasePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !strings.EqualFold(filepath.Ext(path), ".svg") {
+			return nil
+		}
+
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "reading SVG file failed (path: %s)", path)
+		}
+		err = validateSVG(content)
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "file %s", filepath.ToSlash(path[len(p.BasePath)+1:])))
+		}
+		return nil
+	})
+	// Packages without files have no SVG files
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return errs.Err()
+}
+
+// validateSVG checks that an SVG image has no script elements, foreign objects, event handler attributes or
+// links and animated values with javascript or data URLs.
+func validateSVG(content []byte) error {
+	decoder := newSVGDecoder(content)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "parsing SVG failed")
+		}
+
+		element, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		elementName := strings.ToLower(element.Name.Local)
+		switch elementName {
+		case "script":
+			return errors.New("script elements are not allowed")
+		case "foreignobject":
+			return errors.New("foreignObject elements are not allowed")
+		}
+
+		for _, attr := range element.Attr {
+			name := strings.ToLower(attr.Name.Local)
+			if strings.HasPrefix(name, "on") {
+				return fmt.Errorf("event handler attribute \"%s\" of element \"%s\" is not allowed", attr.Name.Local, element.Name.Local)
+			}
+
+			var values []string
+			switch {
+			case name == "href" || name == "src":
+				values = []string{attr.Value}
+			case (elementName == "animate" || elementName == "set") && (name == "to" || name == "from" || name == "by"):
+				values = []string{attr.Value}
+			case (elementName == "animate" || elementName == "set") && name == "values":
+				values = strings.Split(attr.Value, ";")
+			}
+			for _, value := range values {
+				if scheme, unsafe := unsafeURLScheme(value); unsafe {
+					return fmt.Errorf("%s URL in attribute \"%s\" of element \"%s\" is not allowed", scheme, attr.Name.Local, element.Name.Local)
+				}
+			}
+		}
+	}
+}
+
+// unsafeURLScheme returns the scheme of URLs that can run code or embed documents. Browsers ignore ASCII
+// whitespace and control characters in URLs, so they are removed before checking the scheme.
+func unsafeURLScheme(value string) (string, bool) {
+	value = strings.ToLower(strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, value))
+
+	for _, scheme := range []string{"javascript", "data"} {
+		if strings.HasPrefix(value, scheme+":") {
+			return scheme, true
+		}
+	}
+	return "", false
+}
+
+func newSVGDecoder(content []byte) *xml.Decoder {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	// Only element and attribute names and ASCII values are checked, these are the same in any of
+	// the encodings used in SVG files
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	return decoder
+}
+
+// parseSVGLength parses lengths in pixels, zero is returned for other units.
+func parseSVGLength(value string) int {
+	value = strings.TrimSuffix(strings.TrimSpace(value), "px")
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f <= 0 {
+		return 0
+	}
+	return int(f)
+}
@@ -0,0 +1,85 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateImage(t *testing.T) {
+	basePath := "../testdata/images"
+
+	tests := []struct {
+		image Image
+		err   string
+	}{
+		{Image{Src: "/screenshot.png", Size: "40x30", Type: "image/png"}, ""},
+		{Image{Src: "/screenshot.png"}, ""},
+		{Image{Src: "/screenshot.png", Size: "30x40", Type: "image/png"}, "declared size 30x40 doesn't match size of file 40x30"},
+		{Image{Src: "/screenshot.png", Type: "image/jpeg"}, "declared type image/jpeg doesn't match type of file image/png"},
+		{Image{Src: "/screenshot.png", Size: "40"}, "invalid size 40, expected format is {width}x{height}"},
+		{Image{Src: "/icon.svg", Size: "32x32", Type: "image/svg+xml"}, ""},
+		{Image{Src: "/viewbox.svg", Size: "64x48", Type: "image/svg+xml"}, ""},
+		{Image{Src: "/relative.svg", Type: "image/svg+xml"}, ""},
+		{Image{Src: "/relative.svg", Size: "32x32"}, ""},
+		{Image{Src: "/viewbox.svg", Size: "32x32"}, ""},
+		{Image{Src: "/viewbox.svg", Size: "32"}, "invalid size 32, expected format is {width}x{height}"},
+		{Image{Src: "/not-an-image.txt"}, "unsupported image format, expected PNG, JPEG or SVG"},
+		{Image{Src: "/missing.png"}, "no such file or directory"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.image.Src+" "+tt.image.Size+" "+tt.image.Type, func(t *testing.T) {
+			err := tt.image.validate(basePath, maxIconFileSize)
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.err)
+			}
+		})
+	}
+}
+
+func TestReadImageMetadata(t *testing.T) {
+	metadata, err := readImageMetadata("../testdata/package/example/1.0.0/img/kibana-envoyproxy.jpg")
+	require.NoError(t, err)
+	assert.Equal(t, &imageMetadata{contentType: ImageTypeJPEG, width: 3340, height: 1882}, metadata)
+}
+
+func TestValidateSVG(t *testing.T) {
+	tests := []struct {
+		svg string
+		err string
+	}{
+		{`<svg width="32" height="32"><path d="M0 0h32v32H0z" fill="#F68536"/></svg>`, ""},
+		{`<svg><a href="https://www.elastic.co"><text>Elastic</text></a></svg>`, ""},
+		{`<svg><script>alert(document.cookie)</script></svg>`, "script elements are not allowed"},
+		{`<svg onload="alert(document.cookie)"></svg>`, `event handler attribute "onload" of element "svg" is not allowed`},
+		{`<svg><rect onClick="alert(1)"/></svg>`, `event handler attribute "onClick" of element "rect" is not allowed`},
+		{`<svg><a xlink:href=" JavaScript:alert(1)"><text>x</text></a></svg>`, `javascript URL in attribute "href" of element "a" is not allowed`},
+		{`<svg><a href="java&#x09;script:alert(1)"><text>x</text></a></svg>`, `javascript URL in attribute "href" of element "a" is not allowed`},
+		{`<svg><a href="&#x0A;javascript:alert(1)"><text>x</text></a></svg>`, `javascript URL in attribute "href" of element "a" is not allowed`},
+		{`<svg><a href="data:text/html;base64,PHNjcmlwdD4="><text>x</text></a></svg>`, `data URL in attribute "href" of element "a" is not allowed`},
+		{`<svg><a><set attributeName="href" to="javascript:alert(1)"/><text>x</text></a></svg>`, `javascript URL in attribute "to" of element "set" is not allowed`},
+		{`<svg><a><animate attributeName="href" values="#;javascript:alert(1)"/><text>x</text></a></svg>`, `javascript URL in attribute "values" of element "animate" is not allowed`},
+		{`<svg><animate attributeName="width" from="0" to="10"/></svg>`, ""},
+		{`<svg><foreignObject><div xmlns="http://www.w3.org/1999/xhtml">x</div></foreignObject></svg>`, "foreignObject elements are not allowed"},
+		{`<svg><path></svg>`, "parsing SVG failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.svg, func(t *testing.T) {
+			err := validateSVG([]byte(tt.svg))
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,289 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package util
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	indexTemplatePriority         = 200
+	indexTemplatePriorityPrefixed = 150
+
+	defaultIgnoreAbove   = 1024
+	defaultScalingFactor = 1000
+)
+
+// IndexTemplate is a composable index template for the indices of a data stream.
+type IndexTemplate struct {
+	IndexPatterns []string          `json:"index_patterns"`
+	Priority      int               `json:"priority"`
+	DataStream    MapStr            `json:"data_stream"`
+	Template      IndexTemplateBody `json:"template"`
+	Meta          MapStr            `json:"_meta"`
+}
+
+// IndexTemplateBody contains the settings and mappings applied to the indices of a data stream.
+type IndexTemplateBody struct {
+	Settings MapStr `json:"settings"`
+	Mappings MapStr `json:"mappings"`
+}
+
+// IndexTemplateName returns the name of the index template of the data stream.
+func (d *DataStream) IndexTemplateName() string {
+	return d.Type + "-" + d.Dataset
+}
+
+// IngestPipelineName returns the name of the ingest pipeline used by the data stream, empty if none.
+func (d *DataStream) IngestPipelineName() string {
+	if d.Elasticsearch != nil && d.Elasticsearch.IngestPipelineName != "" {
+		return d.Elasticsearch.IngestPipelineName
+	}
+	return d.IngestPipeline
+}
+
+// IngestPipelineID returns the ID under which the given ingest pipeline of the data stream is installed.
+// The default pipeline is installed as {type}-{dataset}-{version}, all others get their name appended.
+func (d *DataStream) IngestPipelineID(version, name string) string {
+	id := fmt.Sprintf("%s-%s-%s", d.Type, d.Dataset, version)
+	if name == DefaultPipelineName {
+		return id
+	}
+	return id + "-" + name
+}
+
+// IndexTemplate generates the index template of the data stream. The mappings are generated from
+// the fields of the data stream and the settings and mappings of the manifest are merged on top.
+func (d *DataStream) IndexTemplate(p *Package) (*IndexTemplate, error) {
+	fields, err := d.LoadFields()
+	if err != nil {
+		return nil, errors.Wrap(err, "loading fields failed")
+	}
+
+	indexPattern := d.IndexTemplateName() + "-*"
+	priority := indexTemplatePriority
+	if d.DatasetIsPrefix {
+		indexPattern = d.IndexTemplateName() + ".*-*"
+		priority = indexTemplatePriorityPrefixed
+	}
+
+	ilmPolicy := d.IlmPolicy
+	if ilmPolicy == "" {
+		ilmPolicy = d.Type
+	}
+
+	settings := MapStr{
+		"index": MapStr{
+			"lifecycle": MapStr{
+				"name": ilmPolicy,
+			},
+		},
+	}
+	if pipeline := d.IngestPipelineName(); pipeline != "" {
+		settings.Put("index.default_pipeline", d.IngestPipelineID(p.Version, pipeline))
+	}
+
+	mappings := fieldsToMappings(fields)
+
+	if d.Elasticsearch != nil {
+		manifestSettings, err := expandDottedKeys(d.Elasticsearch.IndexTemplateSettings)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid index template settings")
+		}
+		settings.DeepUpdate(manifestSettings)
+
+		manifestMappings, err := expandDottedKeys(d.Elasticsearch.IndexTemplateMappings)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid index template mappings")
+		}
+		mappings.DeepUpdate(manifestMappings)
+	}
+
+	return &IndexTemplate{
+		IndexPatterns: []string{indexPattern},
+		Priority:      priority,
+		DataStream: MapStr{
+			"hidden": d.Hidden,
+		},
+		Template: IndexTemplateBody{
+			Settings: settings,
+			Mappings: mappings,
+		},
+		Meta: MapStr{
+			"package": MapStr{
+				"name":    p.Name,
+				"version": p.Version,
+			},
+		},
+	}, nil
+}
+
+// fieldsToMappings converts the flattened fields to the properties of the index mappings. Objects with
+// an object_type and fields with wildcards in their names are converted to dynamic templates.
+func fieldsToMappings(fields []Field) MapStr {
+	properties := MapStr{}
+	var dynamicTemplates []MapStr
+
+	for _, f := range fields {
+		if (f.Type == "object" && f.ObjectType != "") || strings.Contains(f.Name, "*") {
+			dynamicTemplates = append(dynamicTemplates, fieldDynamicTemplate(f))
+			continue
+		}
+
+		mapping := fieldMapping(f)
+		if mapping == nil {
+			continue
+		}
+		putProperty(properties, strings.Split(f.Name, "."), mapping)
+	}
+
+	mappings := MapStr{
+		"date_detection": false,
+		"properties":     properties,
+	}
+	if len(dynamicTemplates) > 0 {
+		mappings["dynamic_templates"] = dynamicTemplates
+	}
+	return mappings
+}
+
+// fieldMapping returns the mapping of a single field, nil if the field isn't mapped.
+func fieldMapping(f Field) MapStr {
+	var mapping MapStr
+	switch f.Type {
+	case "array":
+		// Arrays are mapped by the type of their elements
+		return nil
+	case "", "keyword":
+		ignoreAbove := f.IgnoreAbove
+		if ignoreAbove == 0 {
+			ignoreAbove = defaultIgnoreAbove
+		}
+		mapping = MapStr{"type": "keyword", "ignore_above": ignoreAbove}
+	case "scaled_float":
+		scalingFactor := f.ScalingFactor
+		if scalingFactor == 0 {
+			scalingFactor = defaultScalingFactor
+		}
+		mapping = MapStr{"type": "scaled_float", "scaling_factor": scalingFactor}
+	case "alias":
+		mapping = MapStr{"type": "alias", "path": f.Path}
+	default:
+		mapping = MapStr{"type": f.Type}
+	}
+
+	if f.Index != nil {
+		mapping["index"] = *f.Index
+	}
+	if f.DocValues != nil {
+		mapping["doc_values"] = *f.DocValues
+	}
+
+	if len(f.MultiFields) > 0 {
+		multiFields := MapStr{}
+		for _, m := range f.MultiFields {
+			multiFields[m.Name] = fieldMapping(Field{Type: m.Type})
+		}
+		mapping["fields"] = multiFields
+	}
+	return mapping
+}
+
+func fieldDynamicTemplate(f Field) MapStr {
+	pathMatch := f.Name
+	if !strings.Contains(pathMatch, "*") {
+		pathMatch += ".*"
+	}
+
+	mappingType := f.Type
+	if f.Type == "object" {
+		mappingType = f.ObjectType
+	}
+
+	mapping := fieldMapping(Field{
+		Type:          mappingType,
+		IgnoreAbove:   f.IgnoreAbove,
+		ScalingFactor: f.ScalingFactor,
+		Index:         f.Index,
+		DocValues:     f.DocValues,
+		MultiFields:   f.MultiFields,
+	})
+
+	return MapStr{
+		f.Name: MapStr{
+			"path_match":         pathMatch,
+			"match_mapping_type": matchMappingType(mappingType),
+			"mapping":            mapping,
+		},
+	}
+}
+
+// matchMappingType returns the JSON data type detected by Elasticsearch for values of the given field type.
+func matchMappingType(fieldType string) string {
+	switch fieldType {
+	case "", "keyword", "text", "wildcard", "constant_keyword", "ip":
+		return "string"
+	case "long", "integer", "short", "byte", "unsigned_long":
+		return "long"
+	case "double", "float", "half_float", "scaled_float":
+		return "double"
+	case "boolean", "object":
+		return fieldType
+	default:
+		return "*"
+	}
+}
+
+// putProperty adds the mapping of a field under the nested properties given by its name levels.
+func putProperty(properties MapStr, levels []string, mapping MapStr) {
+	if len(levels) == 1 {
+		existing, ok := properties[levels[0]].(MapStr)
+		if ok {
+			// The field was already created as parent of other fields
+			existing.Update(mapping)
+			return
+		}
+		properties[levels[0]] = mapping
+		return
+	}
+
+	parent, ok := properties[levels[0]].(MapStr)
+	if !ok {
+		parent = MapStr{}
+		properties[levels[0]] = parent
+	}
+	children, ok := parent["properties"].(MapStr)
+	if !ok {
+		children = MapStr{}
+		parent["properties"] = children
+	}
+	putProperty(children, levels[1:], mapping)
+}
+
+// expandDottedKeys converts keys in dot-notation, like `index.lifecycle.name`, to nested maps, so they
+// can be merged with other settings.
+func expandDottedKeys(m map[string]interface{}) (MapStr, error) {
+	expanded := MapStr{}
+	for k, v := range m {
+		if sub, ok := tryToMapStr(v); ok {
+			var err error
+			v, err = expandDottedKeys(sub)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		entry := MapStr{}
+		_, err := entry.Put(k, v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid key '%s'", k)
+		}
+		expanded.DeepUpdate(entry)
+	}
+	return expanded, nil
+}
@@ -0,0 +1,94 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFieldsToMappings(t *testing.T) {
+	disabled := false
+	fields := []Field{
+		{Name: "host.name", Type: "keyword", MultiFields: []MultiField{{Name: "text", Type: "text"}}},
+		{Name: "host.ip", Type: "ip", DocValues: &disabled},
+		{Name: "labels", Type: "object", ObjectType: "keyword"},
+		{Name: "cpu.pct", Type: "scaled_float"},
+		{Name: "tags", Type: "array"},
+		{Name: "process.threads", Type: "nested"},
+		{Name: "process.threads.id", Type: "long"},
+		{Name: "dns.answers.ttl", Type: "long"},
+		{Name: "dns.answers", Type: "object"},
+	}
+
+	assert.Equal(t, MapStr{
+		"date_detection": false,
+		"dynamic_templates": []MapStr{
+			{
+				"labels": MapStr{
+					"path_match":         "labels.*",
+					"match_mapping_type": "string",
+					"mapping":            MapStr{"type": "keyword", "ignore_above": 1024},
+				},
+			},
+		},
+		"properties": MapStr{
+			"host": MapStr{
+				"properties": MapStr{
+					"name": MapStr{
+						"type":         "keyword",
+						"ignore_above": 1024,
+						"fields":       MapStr{"text": MapStr{"type": "text"}},
+					},
+					"ip": MapStr{"type": "ip", "doc_values": false},
+				},
+			},
+			"cpu": MapStr{
+				"properties": MapStr{
+					"pct": MapStr{"type": "scaled_float", "scaling_factor": 1000},
+				},
+			},
+			"process": MapStr{
+				"properties": MapStr{
+					"threads": MapStr{
+						"type": "nested",
+						"properties": MapStr{
+							"id": MapStr{"type": "long"},
+						},
+					},
+				},
+			},
+			"dns": MapStr{
+				"properties": MapStr{
+					"answers": MapStr{
+						"type": "object",
+						"properties": MapStr{
+							"ttl": MapStr{"type": "long"},
+						},
+					},
+				},
+			},
+		},
+	}, fieldsToMappings(fields))
+}
+
+func TestExpandDottedKeys(t *testing.T) {
+	expanded, err := expandDottedKeys(map[string]interface{}{
+		"index.lifecycle.name": "reference",
+		"index": map[string]interface{}{
+			"number_of_shards": 1,
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, MapStr{
+		"index": MapStr{
+			"lifecycle":        MapStr{"name": "reference"},
+			"number_of_shards": 1,
+		},
+	}, expanded)
+}
@@ -0,0 +1,346 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/joeshaw/multierror"
+	"github.com/pkg/errors"
+	yamlv2 "gopkg.in/yaml.v2"
+)
+
+// ingestPipelineReference matches references to other pipelines of the data stream,
+// e.g. `{{IngestPipeline "pipeline-json" }}`.
+var ingestPipelineReference = regexp.MustCompile(`^\{\{\s*IngestPipeline\s+["']([^"']+)["']\s*\}\}$`)
+
+// ingestProcessors contains the processor types known to Elasticsearch.
+var ingestProcessors = map[string]bool{
+	"append":            true,
+	"attachment":        true,
+	"bytes":             true,
+	"circle":            true,
+	"community_id":      true,
+	"convert":           true,
+	"csv":               true,
+	"date":              true,
+	"date_index_name":   true,
+	"dissect":           true,
+	"dot_expander":      true,
+	"drop":              true,
+	"enrich":            true,
+	"fail":              true,
+	"fingerprint":       true,
+	"foreach":           true,
+	"geoip":             true,
+	"grok":              true,
+	"gsub":              true,
+	"html_strip":        true,
+	"inference":         true,
+	"join":              true,
+	"json":              true,
+	"kv":                true,
+	"lowercase":         true,
+	"network_direction": true,
+	"pipeline":          true,
+	"registered_domain": true,
+	"remove":            true,
+	"rename":            true,
+	"script":            true,
+	"set":               true,
+	"set_security_user": true,
+	"sort":              true,
+	"split":             true,
+	"trim":              true,
+	"uppercase":         true,
+	"uri_parts":         true,
+	"urldecode":         true,
+	"user_agent":        true,
+}
+
+// IngestPipeline is an ingest pipeline of a data stream, converted to JSON.
+type IngestPipeline struct {
+	Name string `json:"name"`
+	// ID is the ID the pipeline is installed with
+	ID       string                 `json:"id"`
+	File     string                 `json:"file"`
+	Pipeline map[string]interface{} `json:"pipeline"`
+}
+
+// LoadIngestPipelines loads all the ingest pipelines of the data stream, sorted by name. References to other
+// pipelines in `pipeline` processors are replaced by the IDs of the pipelines for the given package version.
+func (d *DataStream) LoadIngestPipelines(version string) ([]IngestPipeline, error) {
+	pipelineDir := filepath.Join(d.BasePath, "elasticsearch", DirIngestPipeline)
+	paths, err := filepath.Glob(filepath.Join(pipelineDir, "*"))
+	if err != nil {
+		return nil, err
+	}
+
+	var pipelines []IngestPipeline
+	for _, path := range paths {
+		pipeline, err := readIngestPipelineFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		d.resolveIngestPipelineReferences(version, pipeline)
+
+		pipelines = append(pipelines, IngestPipeline{
+			Name:     name,
+			ID:       d.IngestPipelineID(version, name),
+			File:     filepath.ToSlash(filepath.Join("elasticsearch", DirIngestPipeline, filepath.Base(path))),
+			Pipeline: pipeline,
+		})
+	}
+
+	sort.Slice(pipelines, func(i, j int) bool {
+		return pipelines[i].Name < pipelines[j].Name
+	})
+	return pipelines, nil
+}
+
+// GetIngestPipeline returns the pipeline with the given name, nil if it doesn't exist.
+func GetIngestPipeline(pipelines []IngestPipeline, name string) *IngestPipeline {
+	for i := range pipelines {
+		if pipelines[i].Name == name {
+			return &pipelines[i]
+		}
+	}
+	return nil
+}
+
+// resolveIngestPipelineReferences replaces the names of pipelines referenced by `pipeline` processors with their IDs.
+// Packages can only reference pipelines of the same data stream, other names are rejected by the validation
+// of ingest pipelines and are left unchanged.
+func (d *DataStream) resolveIngestPipelineReferences(version string, v interface{}) {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		if processor, ok := value["pipeline"].(map[string]interface{}); ok {
+			if name, ok := processor["name"].(string); ok {
+				if matches := ingestPipelineReference.FindStringSubmatch(name); matches != nil {
+					processor["name"] = d.IngestPipelineID(version, matches[1])
+				}
+			}
+		}
+		for _, child := range value {
+			d.resolveIngestPipelineReferences(version, child)
+		}
+	case []interface{}:
+		for _, child := range value {
+			d.resolveIngestPipelineReferences(version, child)
+		}
+	}
+}
+
+// validateIngestPipelines checks the processors of all the ingest pipelines of the data stream, that references
+// to other pipelines can be resolved and that all pipelines are used.
+func (d *DataStream) validateIngestPipelines() error {
+	pipelineDir := filepath.Join(d.BasePath, "elasticsearch", DirIngestPipeline)
+	paths, err := filepath.Glob(filepath.Join(pipelineDir, "*"))
+	if err != nil {
+		return err
+	}
+
+	names := map[string]bool{}
+	for _, path := range paths {
+		names[strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))] = true
+	}
+
+	var errs multierror.Errors
+	referenced := map[string]bool{}
+	for _, path := range paths {
+		file := filepath.ToSlash(filepath.Join("elasticsearch", DirIngestPipeline, filepath.Base(path)))
+
+		pipeline, err := readIngestPipelineFile(path)
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "file %s", file))
+			continue
+		}
+
+		v := ingestPipelineValidator{file: file, names: names, referenced: referenced}
+		processors, ok := pipeline["processors"]
+		if !ok {
+			errs = append(errs, fmt.Errorf("file %s: processors are missing", file))
+		} else {
+			v.validateProcessors("processors", processors)
+		}
+		if onFailure, ok := pipeline["on_failure"]; ok {
+			v.validateProcessors("on_failure", onFailure)
+		}
+		errs = append(errs, v.errs...)
+	}
+
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		if name == DefaultPipelineName || name == d.IngestPipelineName() || referenced[name] {
+			continue
+		}
+		errs = append(errs, fmt.Errorf("file %s: ingest pipeline is not referenced by the data stream or any other pipeline",
+			filepath.ToSlash(filepath.Join("elasticsearch", DirIngestPipeline, filepath.Base(path)))))
+	}
+	return errs.Err()
+}
+
+type ingestPipelineValidator struct {
+	file string
+	// names contains the names of all pipelines of the data stream
+	names map[string]bool
+	// referenced collects the names of the pipelines referenced by `pipeline` processors
+	referenced map[string]bool
+	errs       multierror.Errors
+}
+
+func (v *ingestPipelineValidator) errorf(path, format string, args ...interface{}) {
+	v.errs = append(v.errs, fmt.Errorf("file %s: %s: %s", v.file, path, fmt.Sprintf(format, args...)))
+}
+
+func (v *ingestPipelineValidator) validateProcessors(path string, value interface{}) {
+	processors, ok := value.([]interface{})
+	if !ok {
+		v.errorf(path, "expected list of processors but type is %T", value)
+		return
+	}
+
+	for i, processor := range processors {
+		v.validateProcessor(fmt.Sprintf("%s[%d]", path, i), processor)
+	}
+}
+
+func (v *ingestPipelineValidator) validateProcessor(path string, value interface{}) {
+	processor, ok := value.(map[string]interface{})
+	if !ok || len(processor) != 1 {
+		v.errorf(path, "processor must be an object with a single key")
+		return
+	}
+
+	for processorType, c := range processor {
+		if !ingestProcessors[processorType] {
+			v.errorf(path, "unknown processor type \"%s\"", processorType)
+			return
+		}
+
+		config, ok := c.(map[string]interface{})
+		if !ok {
+			v.errorf(path, "configuration of processor \"%s\" must be an object", processorType)
+			return
+		}
+
+		switch processorType {
+		case "pipeline":
+			v.validatePipelineReference(path, config["name"])
+		case "foreach":
+			if p, ok := config["processor"]; ok {
+				v.validateProcessor(path+".foreach.processor", p)
+			} else {
+				v.errorf(path, "processor \"foreach\" requires a processor")
+			}
+		}
+
+		if onFailure, ok := config["on_failure"]; ok {
+			v.validateProcessors(path+"."+processorType+".on_failure", onFailure)
+		}
+	}
+}
+
+func (v *ingestPipelineValidator) validatePipelineReference(path string, value interface{}) {
+	name, ok := value.(string)
+	if !ok {
+		v.errorf(path, "processor \"pipeline\" requires a name")
+		return
+	}
+
+	matches := ingestPipelineReference.FindStringSubmatch(name)
+	if matches == nil {
+		v.errorf(path, "pipeline \"%s\" must be referenced as {{IngestPipeline \"name\"}}", name)
+		return
+	}
+
+	v.referenced[matches[1]] = true
+	if !v.names[matches[1]] {
+		v.errorf(path, "referenced ingest pipeline \"%s\" does not exist", matches[1])
+	}
+}
+
+// readIngestPipelineFile parses an ingest pipeline in JSON or YAML format.
+func readIngestPipelineFile(pipelinePath string) (map[string]interface{}, error) {
+	f, err := ioutil.ReadFile(pipelinePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading ingest pipeline file failed (path: %s)", pipelinePath)
+	}
+
+	ext := filepath.Ext(pipelinePath)
+	switch ext {
+	case ".json":
+		var m map[string]interface{}
+		err = json.Unmarshal(f, &m)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unmarshaling ingest pipeline failed (path: %s)", pipelinePath)
+		}
+		return m, nil
+	case ".yml":
+		var m map[string]interface{}
+		err = yamlv2.Unmarshal(f, &m)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unmarshaling ingest pipeline failed (path: %s)", pipelinePath)
+		}
+		converted, err := convertYAMLMaps(m)
+		if err != nil {
+			return nil, errors.Wrapf(err, "converting ingest pipeline failed (path: %s)", pipelinePath)
+		}
+		return converted.(map[string]interface{}), nil
+	default:
+		return nil, fmt.Errorf("unsupported pipeline extension (path: %s, ext: %s)", pipelinePath, ext)
+	}
+}
+
+// convertYAMLMaps converts the maps with interface keys created by the YAML parser to maps with
+// string keys, so they can be encoded to JSON.
+func convertYAMLMaps(v interface{}) (interface{}, error) {
+	switch value := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(value))
+		for k, child := range value {
+			key, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("unsupported key type %T (key: %v)", k, k)
+			}
+			converted, err := convertYAMLMaps(child)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = converted
+		}
+		return m, nil
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(value))
+		for key, child := range value {
+			converted, err := convertYAMLMaps(child)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = converted
+		}
+		return m, nil
+	case []interface{}:
+		s := make([]interface{}, len(value))
+		for i, child := range value {
+			converted, err := convertYAMLMaps(child)
+			if err != nil {
+				return nil, err
+			}
+			s[i] = converted
+		}
+		return s, nil
+	default:
+		return v, nil
+	}
+}
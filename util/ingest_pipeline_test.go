@@ -0,0 +1,76 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package util
+
+import (
+	"testing"
+
+	"github.com/joeshaw/multierror"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveIngestPipelineReferences(t *testing.T) {
+	d := DataStream{Type: "logs", Dataset: "nginx.access"}
+	pipeline := map[string]interface{}{
+		"processors": []interface{}{
+			map[string]interface{}{
+				"pipeline": map[string]interface{}{"name": `{{IngestPipeline "pipeline-json" }}`},
+			},
+			map[string]interface{}{
+				"pipeline": map[string]interface{}{"name": "{{IngestPipeline 'default'}}"},
+			},
+		},
+	}
+
+	d.resolveIngestPipelineReferences("1.2.0", pipeline)
+	assert.Equal(t, map[string]interface{}{
+		"processors": []interface{}{
+			map[string]interface{}{
+				"pipeline": map[string]interface{}{"name": "logs-nginx.access-1.2.0-pipeline-json"},
+			},
+			map[string]interface{}{
+				"pipeline": map[string]interface{}{"name": "logs-nginx.access-1.2.0"},
+			},
+		},
+	}, pipeline)
+}
+
+func TestConvertYAMLMaps(t *testing.T) {
+	converted, err := convertYAMLMaps(map[interface{}]interface{}{
+		"processors": []interface{}{
+			map[interface{}]interface{}{"set": map[interface{}]interface{}{"field": "a", "value": 1}},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"processors": []interface{}{
+			map[string]interface{}{"set": map[string]interface{}{"field": "a", "value": 1}},
+		},
+	}, converted)
+
+	_, err = convertYAMLMaps(map[interface{}]interface{}{1: "a"})
+	assert.Error(t, err)
+}
+
+func TestValidateIngestPipelines(t *testing.T) {
+	d := DataStream{BasePath: "../testdata/ingest_pipeline/invalid", IngestPipeline: DefaultPipelineName}
+	err := d.validateIngestPipelines()
+	require.Error(t, err)
+
+	var messages []string
+	for _, e := range err.(*multierror.MultiError).Errors {
+		messages = append(messages, e.Error())
+	}
+	assert.ElementsMatch(t, []string{
+		`file elasticsearch/ingest_pipeline/default.yml: processors[1]: referenced ingest pipeline "pipeline-missing" does not exist`,
+		`file elasticsearch/ingest_pipeline/default.yml: processors[2]: pipeline "external-pipeline" must be referenced as {{IngestPipeline "name"}}`,
+		`file elasticsearch/ingest_pipeline/default.yml: processors[3]: unknown processor type "unknown"`,
+		`file elasticsearch/ingest_pipeline/default.yml: processors[4]: processor must be an object with a single key`,
+		`file elasticsearch/ingest_pipeline/default.yml: processors[5].rename.on_failure: expected list of processors but type is string`,
+		`file elasticsearch/ingest_pipeline/pipeline-unused.yml: processors[0].foreach.processor: unknown processor type "unknown"`,
+		`file elasticsearch/ingest_pipeline/pipeline-unused.yml: ingest pipeline is not referenced by the data stream or any other pipeline`,
+	}, messages)
+}
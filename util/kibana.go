@@ -0,0 +1,145 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/joeshaw/multierror"
+	"github.com/pkg/errors"
+)
+
+const DirKibana = "kibana"
+
+// fleetIndexPatterns are the index patterns installed by Fleet. Saved objects of packages can reference
+// them without shipping them.
+var fleetIndexPatterns = map[string]bool{
+	"logs-*":       true,
+	"metrics-*":    true,
+	"events-*":     true,
+	"synthetics-*": true,
+}
+
+// SavedObject is a Kibana saved object shipped in the kibana directory of a package.
+type SavedObject struct {
+	Type        string                 `json:"type"`
+	ID          string                 `json:"id"`
+	Title       string                 `json:"title,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	File        string                 `json:"file"`
+	References  []SavedObjectReference `json:"references"`
+}
+
+// SavedObjectReference is a reference from a saved object to another one.
+type SavedObjectReference struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+type savedObjectFile struct {
+	ID         string `json:"id"`
+	Type       string `json:"type"`
+	Attributes struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+	} `json:"attributes"`
+	References []SavedObjectReference `json:"references"`
+}
+
+// LoadSavedObjects loads all the Kibana saved objects of the package, sorted by type and ID. The type and ID
+// are taken from the directory and file name, unless they are set in the object itself.
+func (p *Package) LoadSavedObjects() ([]SavedObject, error) {
+	paths, err := filepath.Glob(filepath.Join(p.BasePath, DirKibana, "*", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []SavedObject
+	for _, path := range paths {
+		object, err := readSavedObjectFile(p.BasePath, path)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, object)
+	}
+
+	sort.SliceStable(objects, func(i, j int) bool {
+		if objects[i].Type != objects[j].Type {
+			return objects[i].Type < objects[j].Type
+		}
+		return objects[i].ID < objects[j].ID
+	})
+	return objects, nil
+}
+
+func readSavedObjectFile(basePath, path string) (SavedObject, error) {
+	file := filepath.ToSlash(path[len(basePath)+1:])
+
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return SavedObject{}, errors.Wrapf(err, "reading saved object failed (path: %s)", path)
+	}
+
+	var f savedObjectFile
+	err = json.Unmarshal(body, &f)
+	if err != nil {
+		return SavedObject{}, errors.Wrapf(err, "file %s: unmarshaling saved object failed", file)
+	}
+
+	object := SavedObject{
+		Type:        f.Type,
+		ID:          f.ID,
+		Title:       f.Attributes.Title,
+		Description: f.Attributes.Description,
+		File:        file,
+		References:  f.References,
+	}
+	if object.Type == "" {
+		object.Type = filepath.Base(filepath.Dir(path))
+	}
+	if object.ID == "" {
+		object.ID = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	// Instead of return `null` in case of an empty array, return []
+	if object.References == nil {
+		object.References = []SavedObjectReference{}
+	}
+	return object, nil
+}
+
+// validateSavedObjects checks that the saved objects of the package can be parsed and that they only
+// reference saved objects of the same package, or index patterns installed by Fleet.
+func (p *Package) validateSavedObjects() error {
+	objects, err := p.LoadSavedObjects()
+	if err != nil {
+		return err
+	}
+
+	var errs multierror.Errors
+	known := map[string]bool{}
+	for _, o := range objects {
+		key := o.Type + "/" + o.ID
+		if known[key] {
+			errs = append(errs, fmt.Errorf("file %s: saved object %s is defined more than once", o.File, key))
+		}
+		known[key] = true
+	}
+
+	for _, o := range objects {
+		for _, r := range o.References {
+			if known[r.Type+"/"+r.ID] || (r.Type == "index-pattern" && fleetIndexPatterns[r.ID]) {
+				continue
+			}
+			errs = append(errs, fmt.Errorf("file %s: reference \"%s\" to %s \"%s\" not found in package", o.File, r.Name, r.Type, r.ID))
+		}
+	}
+	return errs.Err()
+}
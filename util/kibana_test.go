@@ -0,0 +1,41 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadSavedObjects(t *testing.T) {
+	p := Package{BasePath: "../testdata/package/example/1.0.0"}
+
+	objects, err := p.LoadSavedObjects()
+	require.NoError(t, err)
+	require.Len(t, objects, 7)
+
+	dashboard := objects[0]
+	assert.Equal(t, "dashboard", dashboard.Type)
+	assert.Equal(t, "0c610510-5cbd-11e9-8477-077ec9664dbd", dashboard.ID)
+	assert.Equal(t, "Filebeat-Envoyproxy-Overview", dashboard.Title)
+	assert.Equal(t, "kibana/dashboard/0c610510-5cbd-11e9-8477-077ec9664dbd.json", dashboard.File)
+	assert.Len(t, dashboard.References, 6)
+
+	for _, o := range objects[1:] {
+		assert.Equal(t, "visualization", o.Type)
+	}
+}
+
+func TestValidateSavedObjects(t *testing.T) {
+	p := Package{BasePath: "../testdata/kibana/invalid"}
+	err := p.validateSavedObjects()
+	assert.EqualError(t, err, "4 errors: "+
+		"file kibana/visualization/requests.json: saved object visualization/requests is defined more than once; "+
+		`file kibana/dashboard/overview.json: reference "panel_1" to visualization "missing" not found in package; `+
+		`file kibana/dashboard/overview.json: reference "panel_2" to search "requests" not found in package; `+
+		`file kibana/visualization/requests.json: reference "kibanaSavedObjectMeta.searchSourceJSON.filter[0].meta.index" to index-pattern "nginx-*" not found in package`)
+}
@@ -0,0 +1,161 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package util
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/pkg/errors"
+)
+
+var lookupIndex *LookupIndex
+
+// LookupResult is a data stream of a package version matching a lookup.
+type LookupResult struct {
+	Package         string `json:"package"`
+	Version         string `json:"version"`
+	DataStream      string `json:"data_stream"`
+	Dataset         string `json:"dataset"`
+	DatasetIsPrefix bool   `json:"dataset_is_prefix,omitempty"`
+	// Field and Type are only set for field lookups
+	Field string `json:"field,omitempty"`
+	Type  string `json:"type,omitempty"`
+
+	versionSemVer *semver.Version
+}
+
+// LookupIndex allows to find the data streams defining a dataset or a field.
+type LookupIndex struct {
+	datasets       map[string][]LookupResult
+	prefixDatasets []LookupResult
+	fields         map[string][]LookupResult
+	// fieldPatterns contains the fields with wildcards in their names
+	fieldPatterns []LookupResult
+}
+
+// GetLookupIndex returns the lookup index of all existing packages.
+// Same as the packages, the index is built on the first request and then served from memory.
+func GetLookupIndex(packagesBasePaths []string) (*LookupIndex, error) {
+	if lookupIndex != nil {
+		return lookupIndex, nil
+	}
+
+	packages, err := GetPackages(packagesBasePaths)
+	if err != nil {
+		return nil, err
+	}
+
+	lookupIndex, err = NewLookupIndex(packages)
+	if err != nil {
+		return nil, errors.Wrap(err, "building lookup index failed")
+	}
+	return lookupIndex, nil
+}
+
+// NewLookupIndex indexes the datasets and fields of all data streams of the given packages.
+func NewLookupIndex(packages Packages) (*LookupIndex, error) {
+	index := &LookupIndex{
+		datasets: map[string][]LookupResult{},
+		fields:   map[string][]LookupResult{},
+	}
+
+	seen := map[string]bool{}
+	for _, p := range packages {
+		// If a version exists multiple times, the first one wins
+		if seen[p.Name+"@"+p.Version] {
+			continue
+		}
+		seen[p.Name+"@"+p.Version] = true
+
+		for _, d := range p.DataStreams {
+			result := LookupResult{
+				Package:         p.Name,
+				Version:         p.Version,
+				DataStream:      d.Path,
+				Dataset:         d.Dataset,
+				DatasetIsPrefix: d.DatasetIsPrefix,
+				versionSemVer:   p.versionSemVer,
+			}
+
+			if d.DatasetIsPrefix {
+				index.prefixDatasets = append(index.prefixDatasets, result)
+			} else {
+				index.datasets[d.Dataset] = append(index.datasets[d.Dataset], result)
+			}
+
+			fields, err := d.LoadFields()
+			if err != nil {
+				return nil, errors.Wrapf(err, "loading fields failed (package: %s, version: %s, data stream: %s)", p.Name, p.Version, d.Path)
+			}
+
+			for _, f := range fields {
+				fieldResult := result
+				fieldResult.Field = f.Name
+				fieldResult.Type = f.Type
+
+				if strings.Contains(f.Name, "*") {
+					index.fieldPatterns = append(index.fieldPatterns, fieldResult)
+					continue
+				}
+				index.fields[f.Name] = append(index.fields[f.Name], fieldResult)
+			}
+		}
+	}
+	return index, nil
+}
+
+// Dataset returns the data streams writing to the given dataset.
+func (i *LookupIndex) Dataset(dataset string) []LookupResult {
+	results := append([]LookupResult{}, i.datasets[dataset]...)
+	for _, r := range i.prefixDatasets {
+		if dataset == r.Dataset || strings.HasPrefix(dataset, r.Dataset+".") {
+			results = append(results, r)
+		}
+	}
+	sortLookupResults(results)
+	return results
+}
+
+// Field returns the data streams defining the given field. Wildcards in field definitions
+// match exactly one level of the field name.
+func (i *LookupIndex) Field(name string) []LookupResult {
+	results := append([]LookupResult{}, i.fields[name]...)
+	for _, r := range i.fieldPatterns {
+		if matchFieldPattern(r.Field, name) {
+			results = append(results, r)
+		}
+	}
+	sortLookupResults(results)
+	return results
+}
+
+func matchFieldPattern(pattern, name string) bool {
+	patternLevels := strings.Split(pattern, ".")
+	nameLevels := strings.Split(name, ".")
+	if len(patternLevels) != len(nameLevels) {
+		return false
+	}
+
+	for i := range patternLevels {
+		if patternLevels[i] != "*" && patternLevels[i] != nameLevels[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sortLookupResults(results []LookupResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Package != results[j].Package {
+			return results[i].Package < results[j].Package
+		}
+		if !results[i].versionSemVer.Equal(results[j].versionSemVer) {
+			return results[i].versionSemVer.LessThan(results[j].versionSemVer)
+		}
+		return results[i].DataStream < results[j].DataStream
+	})
+}
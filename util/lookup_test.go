@@ -0,0 +1,31 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchFieldPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		matches bool
+	}{
+		{"aws.tags.*", "aws.tags.owner", true},
+		{"aws.tags.*", "aws.tags", false},
+		{"aws.tags.*", "aws.tags.owner.name", false},
+		{"aws.*.id", "aws.ec2.id", true},
+		{"aws.*.id", "aws.ec2.name", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.matches, matchFieldPattern(tt.pattern, tt.name))
+		})
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"strings"
 
 	"github.com/Masterminds/semver/v3"
+	"github.com/joeshaw/multierror"
 	"github.com/pkg/errors"
 
 	ucfg "github.com/elastic/go-ucfg"
@@ -24,32 +25,6 @@ const (
 	packagePathPrefix = "/package"
 )
 
-var CategoryTitles = map[string]string{
-	"aws":               "AWS",
-	"azure":             "Azure",
-	"cloud":             "Cloud",
-	"config_management": "Config management",
-	"containers":        "Containers",
-	"crm":               "CRM",
-	"custom":            "Custom",
-	"datastore":         "Datastore",
-	"elastic_stack":     "Elastic Stack",
-	"google_cloud":      "Google Cloud",
-	"kubernetes":        "Kubernetes",
-	"languages":         "Languages",
-	"message_queue":     "Message Queue",
-	"monitoring":        "Monitoring",
-	"network":           "Network",
-	"notification":      "Notification",
-	"os_system":         "OS & System",
-	"productivity":      "Productivity",
-	"security":          "Security",
-	"support":           "Support",
-	"ticketing":         "Ticketing",
-	"version_control":   "Version Control",
-	"web":               "Web",
-}
-
 type Package struct {
 	BasePackage   `config:",inline" json:",inline" yaml:",inline"`
 	FormatVersion string `config:"format_version" json:"format_version" yaml:"format_version"`
@@ -57,14 +32,17 @@ type Package struct {
 	Readme          *string `config:"readme,omitempty" json:"readme,omitempty" yaml:"readme,omitempty"`
 	License         string  `config:"license,omitempty" json:"license,omitempty" yaml:"license,omitempty"`
 	versionSemVer   *semver.Version
-	Categories      []string         `config:"categories" json:"categories"`
-	Conditions      *Conditions      `config:"conditions,omitempty" json:"conditions,omitempty" yaml:"conditions,omitempty"`
-	Screenshots     []Image          `config:"screenshots,omitempty" json:"screenshots,omitempty" yaml:"screenshots,omitempty"`
-	Assets          []string         `config:"assets,omitempty" json:"assets,omitempty" yaml:"assets,omitempty"`
-	PolicyTemplates []PolicyTemplate `config:"policy_templates,omitempty" json:"policy_templates,omitempty" yaml:"policy_templates,omitempty"`
-	DataStreams     []*DataStream    `config:"data_streams,omitempty" json:"data_streams,omitempty" yaml:"data_streams,omitempty"`
-	Owner           *Owner           `config:"owner,omitempty" json:"owner,omitempty" yaml:"owner,omitempty"`
-	Vars            []Variable       `config:"vars" json:"vars,omitempty" yaml:"vars,omitempty"`
+	Categories      []string           `config:"categories" json:"categories"`
+	Conditions      *Conditions        `config:"conditions,omitempty" json:"conditions,omitempty" yaml:"conditions,omitempty"`
+	Screenshots     []Image            `config:"screenshots,omitempty" json:"screenshots,omitempty" yaml:"screenshots,omitempty"`
+	Assets          []string           `config:"assets,omitempty" json:"assets,omitempty" yaml:"assets,omitempty"`
+	PolicyTemplates []PolicyTemplate   `config:"policy_templates,omitempty" json:"policy_templates,omitempty" yaml:"policy_templates,omitempty"`
+	DataStreams     []*DataStream      `config:"data_streams,omitempty" json:"data_streams,omitempty" yaml:"data_streams,omitempty"`
+	Owner           *Owner             `config:"owner,omitempty" json:"owner,omitempty" yaml:"owner,omitempty"`
+	Vars            []Variable         `config:"vars" json:"vars,omitempty" yaml:"vars,omitempty"`
+	Requires        []Requirement      `config:"requires,omitempty" json:"requires,omitempty" yaml:"requires,omitempty"`
+	Yanked          bool               `config:"yanked,omitempty" json:"yanked,omitempty" yaml:"yanked,omitempty"`
+	Changelog       []ChangelogRelease `json:"changelog,omitempty" yaml:"changelog,omitempty"`
 
 	// Local path to the package dir
 	BasePath string `json:"-" yaml:"-"`
@@ -246,6 +224,11 @@ func NewPackage(basePath string) (*Package, error) {
 		}
 	}
 
+	err = p.loadRequirements()
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid requirements")
+	}
+
 	if p.Release == "" {
 		p.Release = DefaultRelease
 	}
@@ -269,6 +252,11 @@ func NewPackage(basePath string) (*Package, error) {
 		p.Readme = &readmePathShort
 	}
 
+	err = p.loadChangelog()
+	if err != nil {
+		return nil, errors.Wrapf(err, "loading changelog failed (path '%s')", p.BasePath)
+	}
+
 	// Assign download path to be part of the output
 	p.Download = p.GetDownloadPath()
 	p.Path = p.GetUrlPath()
@@ -295,6 +283,17 @@ func (p *Package) HasCategory(category string) bool {
 	return false
 }
 
+// HasCategoryOrSubcategory returns true if the package is in the category or in one of its subcategories.
+func (p *Package) HasCategoryOrSubcategory(category string) bool {
+	for _, c := range p.Categories {
+		if IsSubcategory(c, category) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (p *Package) HasKibanaVersion(version *semver.Version) bool {
 
 	// If the version is not specified, it is for all versions
@@ -305,6 +304,15 @@ func (p *Package) HasKibanaVersion(version *semver.Version) bool {
 	return p.Conditions.kibanaConstraint.Check(version)
 }
 
+func (p *Package) MatchesVersion(constraint *semver.Constraints) bool {
+	// If no constraint is specified, all versions match
+	if constraint == nil || p.versionSemVer == nil {
+		return true
+	}
+
+	return constraint.Check(p.versionSemVer)
+}
+
 func (p *Package) IsNewerOrEqual(pp Package) bool {
 	return !p.versionSemVer.LessThan(pp.versionSemVer)
 }
@@ -394,30 +402,67 @@ func (p *Package) Validate() error {
 	}
 
 	for _, c := range p.Categories {
-		if _, ok := CategoryTitles[c]; !ok {
+		if _, ok := GetCategory(c); !ok {
 			return fmt.Errorf("invalid category: %s", c)
 		}
 	}
 
-	for _, i := range p.Icons {
-		_, err := os.Stat(filepath.Join(p.BasePath, i.Src))
+	err = validateImages(p.BasePath, p.Icons, maxIconFileSize)
+	if err != nil {
+		return errors.Wrap(err, "invalid icons")
+	}
+
+	err = validateImages(p.BasePath, p.Screenshots, maxScreenshotFileSize)
+	if err != nil {
+		return errors.Wrap(err, "invalid screenshots")
+	}
+
+	for _, t := range p.PolicyTemplates {
+		err = validateImages(p.BasePath, t.Icons, maxIconFileSize)
 		if err != nil {
-			return err
+			return errors.Wrapf(err, "invalid icons of policy template %s", t.Name)
 		}
-	}
 
-	for _, s := range p.Screenshots {
-		_, err := os.Stat(filepath.Join(p.BasePath, s.Src))
+		err = validateImages(p.BasePath, t.Screenshots, maxScreenshotFileSize)
 		if err != nil {
-			return err
+			return errors.Wrapf(err, "invalid screenshots of policy template %s", t.Name)
 		}
 	}
 
+	err = p.validateSVGFiles()
+	if err != nil {
+		return errors.Wrap(err, "validating SVG files failed")
+	}
+
 	err = p.validateVersionConsistency()
 	if err != nil {
 		return errors.Wrap(err, "version in manifest file is not consistent with path")
 	}
 
+	err = validateVariableDefinitions(p.Vars)
+	if err != nil {
+		return errors.Wrap(err, "invalid package variables")
+	}
+
+	for _, t := range p.PolicyTemplates {
+		for _, i := range t.Inputs {
+			err = validateVariableDefinitions(i.Vars)
+			if err != nil {
+				return errors.Wrapf(err, "invalid variables of input (policy template: %s, input: %s)", t.Name, i.Type)
+			}
+		}
+	}
+
+	err = p.validateInputTemplates()
+	if err != nil {
+		return errors.Wrap(err, "validating input templates failed")
+	}
+
+	err = p.validateSavedObjects()
+	if err != nil {
+		return errors.Wrap(err, "validating Kibana saved objects failed")
+	}
+
 	return p.ValidateDataStreams()
 }
 
@@ -486,12 +531,71 @@ func (p *Package) LoadDataSets() error {
 			return err
 		}
 
-		// TODO: Validate that each input specified in a stream also is defined in the package
-
 		p.DataStreams = append(p.DataStreams, d)
 	}
 
-	return nil
+	if PackageValidationDisabled {
+		return nil
+	}
+
+	err = p.validateInputs()
+	if err != nil {
+		return err
+	}
+	return p.validateDocs()
+}
+
+// validateInputs cross-checks the inputs of the streams with the inputs of the policy templates.
+func (p *Package) validateInputs() error {
+	var errs multierror.Errors
+
+	dataStreams := map[string]*DataStream{}
+	for _, d := range p.DataStreams {
+		dataStreams[d.Path] = d
+	}
+
+	for _, d := range p.DataStreams {
+		for _, s := range d.Streams {
+			var found bool
+			for _, t := range p.PolicyTemplates {
+				for _, i := range t.Inputs {
+					if i.Type == s.Input {
+						found = true
+					}
+				}
+			}
+			if !found {
+				errs = append(errs, fmt.Errorf("input \"%s\" of stream in data stream \"%s\" is not defined in any policy template", s.Input, d.Path))
+			}
+		}
+	}
+
+	for _, t := range p.PolicyTemplates {
+		for _, path := range t.DataStreams {
+			if _, ok := dataStreams[path]; !ok {
+				errs = append(errs, fmt.Errorf("data stream \"%s\" of policy template \"%s\" does not exist", path, t.Name))
+			}
+		}
+
+		for _, i := range t.Inputs {
+			// Inputs with their own template don't need streams
+			if i.TemplatePath != "" {
+				continue
+			}
+
+			var used bool
+			for _, d := range p.DataStreams {
+				if t.appliesTo(d) && d.GetStream(i.Type) != nil {
+					used = true
+					break
+				}
+			}
+			if !used {
+				errs = append(errs, fmt.Errorf("input \"%s\" of policy template \"%s\" is not used by any data stream", i.Type, t.Name))
+			}
+		}
+	}
+	return errs.Err()
 }
 
 // ValidateDataStreams loads all dataStreams and with it validates them
@@ -514,6 +618,21 @@ func (p *Package) ValidateDataStreams() error {
 		if err != nil {
 			return errors.Wrapf(err, "validating data stream failed (path: %s)", dataStreamBasePath)
 		}
+
+		err = p.validateStreamTemplates(d)
+		if err != nil {
+			return errors.Wrapf(err, "validating stream templates failed (path: %s)", dataStreamBasePath)
+		}
+	}
+	return nil
+}
+
+// GetDataStream returns the data stream with the given directory name, or nil if it doesn't exist.
+func (p *Package) GetDataStream(path string) *DataStream {
+	for _, d := range p.DataStreams {
+		if d.Path == path {
+			return d
+		}
 	}
 	return nil
 }
@@ -211,3 +211,29 @@ func BenchmarkNewPackage(b *testing.B) {
 		assert.NoError(b, err)
 	}
 }
+
+func TestValidateInputs(t *testing.T) {
+	p := Package{
+		PolicyTemplates: []PolicyTemplate{
+			{
+				Name:        "nginx",
+				DataStreams: []string{"access", "missing"},
+				Inputs: []Input{
+					{Type: "logfile"},
+					{Type: "nginx/metrics"},
+					{Type: "httpjson", TemplatePath: "httpjson.yml.hbs"},
+				},
+			},
+		},
+		DataStreams: []*DataStream{
+			{Path: "access", Streams: []Stream{{Input: "logfile"}}},
+			{Path: "status", Streams: []Stream{{Input: "nginx/metrics"}, {Input: "unknown"}}},
+		},
+	}
+
+	err := p.validateInputs()
+	assert.EqualError(t, err, "3 errors: "+
+		`input "unknown" of stream in data stream "status" is not defined in any policy template; `+
+		`data stream "missing" of policy template "nginx" does not exist; `+
+		`input "nginx/metrics" of policy template "nginx" is not used by any data stream`)
+}
@@ -38,6 +38,26 @@ func GetPackages(packagesBasePaths []string) (Packages, error) {
 	return packageList, nil
 }
 
+// Newest returns the newest version of the package with the given name for which match returns true.
+// If match is nil, all versions are considered. Nil is returned if no version matches.
+func (packages Packages) Newest(name string, match func(p Package) bool) *Package {
+	var newest *Package
+	for i, p := range packages {
+		if p.Name != name {
+			continue
+		}
+
+		if match != nil && !match(p) {
+			continue
+		}
+
+		if newest == nil || !newest.IsNewerOrEqual(p) {
+			newest = &packages[i]
+		}
+	}
+	return newest
+}
+
 func getPackagesFromFilesystem(packagesBasePaths []string) (Packages, error) {
 	packagePaths, err := getPackagePaths(packagesBasePaths)
 	if err != nil {
@@ -51,6 +71,14 @@ func getPackagesFromFilesystem(packagesBasePaths []string) (Packages, error) {
 			return nil, errors.Wrapf(err, "loading package failed (path: %s)", path)
 		}
 
+		// Warnings are logged here, as packages are loaded again for requests of single packages
+		if !PackageValidationDisabled {
+			err = p.warnECSConflicts()
+			if err != nil {
+				return nil, errors.Wrapf(err, "checking fields against ECS failed (path: %s)", path)
+			}
+		}
+
 		pList = append(pList, *p)
 	}
 	return pList, nil
@@ -0,0 +1,222 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package util
+
+import (
+	"fmt"
+	"sort"
+)
+
+const packageScope = "package"
+
+// PackagePolicy is a policy for a package, with the values of the variables at each level.
+type PackagePolicy struct {
+	Package        *PolicyPackage         `json:"package,omitempty"`
+	PolicyTemplate string                 `json:"policy_template,omitempty"`
+	Multiple       *bool                  `json:"multiple,omitempty"`
+	Vars           map[string]interface{} `json:"vars,omitempty"`
+	Inputs         []PolicyInput          `json:"inputs,omitempty"`
+}
+
+// PolicyPackage is the package version a policy is created for.
+type PolicyPackage struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// PolicyInput configures an input of a policy template.
+type PolicyInput struct {
+	PolicyTemplate string                 `json:"policy_template"`
+	Type           string                 `json:"type"`
+	InputGroup     string                 `json:"input_group,omitempty"`
+	Enabled        *bool                  `json:"enabled,omitempty"`
+	Vars           map[string]interface{} `json:"vars,omitempty"`
+	Streams        []PolicyStream         `json:"streams,omitempty"`
+}
+
+// PolicyStream configures the stream of a data stream for the input.
+type PolicyStream struct {
+	DataStream string                 `json:"data_stream"`
+	Type       string                 `json:"type,omitempty"`
+	Dataset    string                 `json:"dataset,omitempty"`
+	Enabled    *bool                  `json:"enabled,omitempty"`
+	Vars       map[string]interface{} `json:"vars,omitempty"`
+}
+
+// PolicyError is an error found in a package policy. Var is only set for errors of a specific variable.
+type PolicyError struct {
+	Scope string `json:"scope"`
+	Var   string `json:"var,omitempty"`
+	Error string `json:"error"`
+}
+
+// DefaultPolicy builds the package policy for the given policy template with the defaults of all variables.
+// The inputEnabled function decides which inputs are enabled. Streams are enabled if their input is enabled,
+// unless they are disabled by default. Nil is returned if the policy template doesn't exist.
+func (p *Package) DefaultPolicy(policyTemplate string, inputEnabled func(inputType string) bool) *PackagePolicy {
+	var t *PolicyTemplate
+	for i := range p.PolicyTemplates {
+		if p.PolicyTemplates[i].Name == policyTemplate {
+			t = &p.PolicyTemplates[i]
+			break
+		}
+	}
+	if t == nil {
+		return nil
+	}
+
+	multiple := true
+	if t.Multiple != nil {
+		multiple = *t.Multiple
+	}
+
+	policy := &PackagePolicy{
+		Package: &PolicyPackage{
+			Name:    p.Name,
+			Version: p.Version,
+		},
+		PolicyTemplate: t.Name,
+		Multiple:       &multiple,
+		Vars:           variableDefaults(p.Vars),
+	}
+
+	for _, i := range t.Inputs {
+		enabled := inputEnabled(i.Type)
+		input := PolicyInput{
+			PolicyTemplate: t.Name,
+			Type:           i.Type,
+			InputGroup:     i.InputGroup,
+			Enabled:        &enabled,
+			Vars:           variableDefaults(i.Vars),
+		}
+
+		for _, d := range p.DataStreams {
+			if !t.appliesTo(d) {
+				continue
+			}
+			s := d.GetStream(i.Type)
+			if s == nil {
+				continue
+			}
+
+			streamEnabled := enabled && isEnabled(s.Enabled)
+			input.Streams = append(input.Streams, PolicyStream{
+				DataStream: d.Path,
+				Type:       d.Type,
+				Dataset:    d.Dataset,
+				Enabled:    &streamEnabled,
+				Vars:       variableDefaults(s.Vars),
+			})
+		}
+		policy.Inputs = append(policy.Inputs, input)
+	}
+	return policy
+}
+
+func variableDefaults(variables []Variable) map[string]interface{} {
+	defaults := map[string]interface{}{}
+	for _, v := range variables {
+		if v.Default != nil {
+			defaults[v.Name] = v.Default
+		}
+	}
+	if len(defaults) == 0 {
+		return nil
+	}
+	return defaults
+}
+
+func isEnabled(enabled *bool) bool {
+	return enabled == nil || *enabled
+}
+
+// ValidatePolicy checks the variable values of a package policy against the variable definitions of the
+// package, the policy template inputs and the streams. Disabled inputs and streams are not checked.
+func (p *Package) ValidatePolicy(policy PackagePolicy) []PolicyError {
+	errs := validateVariableValues(packageScope, p.Vars, policy.Vars)
+
+	for _, i := range policy.Inputs {
+		if !isEnabled(i.Enabled) {
+			continue
+		}
+
+		scope := fmt.Sprintf(inputScopeFormat, i.PolicyTemplate, i.Type)
+		t, input := p.getPolicyTemplateInput(i.PolicyTemplate, i.Type)
+		if input == nil {
+			errs = append(errs, PolicyError{Scope: scope, Error: "input is not defined in the package"})
+			continue
+		}
+		errs = append(errs, validateVariableValues(scope, input.Vars, i.Vars)...)
+
+		for _, s := range i.Streams {
+			if !isEnabled(s.Enabled) {
+				continue
+			}
+
+			scope := fmt.Sprintf(streamScopeFormat, s.DataStream, i.Type)
+			d := p.GetDataStream(s.DataStream)
+			if d == nil || !t.appliesTo(d) {
+				errs = append(errs, PolicyError{Scope: scope, Error: "data stream is not defined for the policy template"})
+				continue
+			}
+			stream := d.GetStream(i.Type)
+			if stream == nil {
+				errs = append(errs, PolicyError{Scope: scope, Error: "data stream has no stream for the input"})
+				continue
+			}
+			errs = append(errs, validateVariableValues(scope, stream.Vars, s.Vars)...)
+		}
+	}
+	return errs
+}
+
+func (p *Package) getPolicyTemplateInput(policyTemplate, inputType string) (*PolicyTemplate, *Input) {
+	for i := range p.PolicyTemplates {
+		t := &p.PolicyTemplates[i]
+		if t.Name != policyTemplate {
+			continue
+		}
+		for j := range t.Inputs {
+			if t.Inputs[j].Type == inputType {
+				return t, &t.Inputs[j]
+			}
+		}
+	}
+	return nil, nil
+}
+
+func validateVariableValues(scope string, variables []Variable, values map[string]interface{}) []PolicyError {
+	var errs []PolicyError
+
+	known := map[string]bool{}
+	for _, v := range variables {
+		known[v.Name] = true
+
+		value, ok := values[v.Name]
+		if !ok || value == nil {
+			if v.Required && v.Default == nil {
+				errs = append(errs, PolicyError{Scope: scope, Var: v.Name, Error: "value is required"})
+			}
+			continue
+		}
+
+		err := v.ValidateValue(value)
+		if err != nil {
+			errs = append(errs, PolicyError{Scope: scope, Var: v.Name, Error: err.Error()})
+		}
+	}
+
+	var unknown []string
+	for name := range values {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		errs = append(errs, PolicyError{Scope: scope, Var: name, Error: "variable is not defined"})
+	}
+	return errs
+}
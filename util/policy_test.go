@@ -0,0 +1,54 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultPolicyValidation(t *testing.T) {
+	disabled := false
+	p := Package{
+		BasePackage: BasePackage{Name: "nginx", Version: "1.0.0"},
+		Vars:        []Variable{{Name: "hosts", Type: "text", Multi: true, Default: []interface{}{"http://127.0.0.1"}}},
+		PolicyTemplates: []PolicyTemplate{
+			{
+				Name: "nginx",
+				Inputs: []Input{
+					{Type: "logfile"},
+					{Type: "nginx/metrics", Vars: []Variable{{Name: "period", Type: "duration", Default: "10s"}}},
+				},
+			},
+		},
+		DataStreams: []*DataStream{
+			{Path: "access", Type: "logs", Dataset: "nginx.access", Streams: []Stream{
+				{Input: "logfile", Vars: []Variable{{Name: "paths", Type: "text", Multi: true, Required: true}}},
+			}},
+			{Path: "status", Type: "metrics", Dataset: "nginx.status", Streams: []Stream{
+				{Input: "nginx/metrics", Enabled: &disabled},
+			}},
+		},
+	}
+
+	policy := p.DefaultPolicy("nginx", func(string) bool { return true })
+	require.NotNil(t, policy)
+	require.Len(t, policy.Inputs, 2)
+	assert.Equal(t, map[string]interface{}{"hosts": []interface{}{"http://127.0.0.1"}}, policy.Vars)
+	assert.Equal(t, map[string]interface{}{"period": "10s"}, policy.Inputs[1].Vars)
+	assert.False(t, *policy.Inputs[1].Streams[0].Enabled)
+
+	// Only the required variable without default is missing
+	assert.Equal(t, []PolicyError{
+		{Scope: "data_stream.access.stream.logfile", Var: "paths", Error: "value is required"},
+	}, p.ValidatePolicy(*policy))
+
+	policy.Inputs[0].Streams[0].Vars = map[string]interface{}{"paths": []interface{}{"/var/log/nginx/access.log"}}
+	assert.Empty(t, p.ValidatePolicy(*policy))
+
+	assert.Nil(t, p.DefaultPolicy("missing", func(string) bool { return true }))
+}
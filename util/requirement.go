@@ -0,0 +1,201 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package util
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/pkg/errors"
+)
+
+// Requirement defines another package which has to be installed together with the package.
+type Requirement struct {
+	Name    string `config:"name" json:"name" yaml:"name"`
+	Version string `config:"version" json:"version" yaml:"version"`
+
+	constraint *semver.Constraints
+}
+
+// Validate is called during Unpack of the manifest.
+func (r *Requirement) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("no name set for requirement")
+	}
+
+	if r.Version == "" {
+		return fmt.Errorf("no version constraint set for requirement: %s", r.Name)
+	}
+	return nil
+}
+
+// Check verifies if the given package satisfies the requirement.
+func (r *Requirement) Check(p Package) bool {
+	if r.Name != p.Name {
+		return false
+	}
+	if r.constraint == nil || p.versionSemVer == nil {
+		return true
+	}
+	return r.constraint.Check(p.versionSemVer)
+}
+
+func (r *Requirement) String() string {
+	return r.Name + "@" + r.Version
+}
+
+func (p *Package) loadRequirements() error {
+	for i, r := range p.Requires {
+		if r.Name == p.Name {
+			return fmt.Errorf("package can't require itself: %s", r.String())
+		}
+
+		constraint, err := semver.NewConstraint(r.Version)
+		if err != nil {
+			return errors.Wrapf(err, "invalid version constraint for requirement: %s", r.String())
+		}
+		p.Requires[i].constraint = constraint
+	}
+	return nil
+}
+
+// requirementSource keeps track of which package introduced a requirement.
+type requirementSource struct {
+	requirement Requirement
+	requiredBy  string
+}
+
+// ResolveRequirements returns the packages which have to be installed together with the given package,
+// including the package itself. Requirements are resolved to the newest version matching all the
+// collected constraints and the Kibana version. Packages are ordered so that requirements come before
+// the packages requiring them.
+func (packages Packages) ResolveRequirements(p Package, kibanaVersion *semver.Version) (Packages, error) {
+	selected := map[string]Package{p.Name: p}
+
+	// Selecting a version can change the constraints of other packages, so the constraints are collected
+	// again from the selected versions until no selection changes. Passes are bounded to stop on versions
+	// whose requirements keep replacing each other.
+	for i := 0; i <= len(packages); i++ {
+		names, constraints := collectConstraints(p.Name, selected)
+
+		// Packages no longer required by the selected versions are dropped
+		reachable := map[string]Package{p.Name: p}
+		changed := false
+		for _, name := range names {
+			if name == p.Name {
+				if !satisfiesAll(p, constraints[name]) {
+					return nil, fmt.Errorf("conflicting requirements for package %s: %s", name, describeConstraints(constraints[name]))
+				}
+				continue
+			}
+
+			if s, ok := selected[name]; ok && satisfiesAll(s, constraints[name]) {
+				reachable[name] = s
+				continue
+			}
+
+			candidate, err := packages.findRequired(name, constraints[name], kibanaVersion)
+			if err != nil {
+				return nil, err
+			}
+			reachable[name] = *candidate
+			changed = true
+		}
+		selected = reachable
+
+		if !changed {
+			return orderRequirements(p.Name, selected), nil
+		}
+	}
+	return nil, fmt.Errorf("requirements of package %s cannot be resolved", p.Name)
+}
+
+// collectConstraints collects the requirements of the selected packages reachable from the root package,
+// by name of the required package. Names are returned in the order they are found.
+func collectConstraints(root string, selected map[string]Package) ([]string, map[string][]requirementSource) {
+	var names []string
+	constraints := map[string][]requirementSource{}
+	visited := map[string]bool{root: true}
+	queue := []string{root}
+	for len(queue) > 0 {
+		current, ok := selected[queue[0]]
+		queue = queue[1:]
+		if !ok {
+			continue
+		}
+
+		for _, r := range current.Requires {
+			if _, found := constraints[r.Name]; !found {
+				names = append(names, r.Name)
+			}
+			constraints[r.Name] = append(constraints[r.Name], requirementSource{
+				requirement: r,
+				requiredBy:  current.Name + "@" + current.Version,
+			})
+
+			if !visited[r.Name] {
+				visited[r.Name] = true
+				queue = append(queue, r.Name)
+			}
+		}
+	}
+	return names, constraints
+}
+
+// orderRequirements orders the selected packages so that requirements come before the packages requiring them.
+func orderRequirements(root string, selected map[string]Package) Packages {
+	var resolved Packages
+	visited := map[string]bool{}
+	var visit func(name string)
+	visit = func(name string) {
+		if visited[name] {
+			return
+		}
+		visited[name] = true
+
+		s := selected[name]
+		for _, r := range s.Requires {
+			visit(r.Name)
+		}
+		resolved = append(resolved, s)
+	}
+	visit(root)
+	return resolved
+}
+
+func (packages Packages) findRequired(name string, sources []requirementSource, kibanaVersion *semver.Version) (*Package, error) {
+	if packages.Newest(name, nil) == nil {
+		return nil, fmt.Errorf("missing required package %s (required by: %s)", name, describeConstraints(sources))
+	}
+
+	candidate := packages.Newest(name, func(p Package) bool {
+		return !p.Yanked && p.HasKibanaVersion(kibanaVersion) && satisfiesAll(p, sources)
+	})
+	if candidate == nil {
+		if kibanaVersion != nil {
+			return nil, fmt.Errorf("no version of package %s satisfies all requirements with Kibana %s: %s", name, kibanaVersion.String(), describeConstraints(sources))
+		}
+		return nil, fmt.Errorf("conflicting requirements for package %s: %s", name, describeConstraints(sources))
+	}
+	return candidate, nil
+}
+
+func satisfiesAll(p Package, sources []requirementSource) bool {
+	for _, s := range sources {
+		if !s.requirement.Check(p) {
+			return false
+		}
+	}
+	return true
+}
+
+func describeConstraints(sources []requirementSource) string {
+	var descriptions []string
+	for _, s := range sources {
+		descriptions = append(descriptions, fmt.Sprintf("%s requires %s", s.requiredBy, s.requirement.Version))
+	}
+	return strings.Join(descriptions, ", ")
+}
@@ -0,0 +1,158 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package util
+
+import (
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRequirementTestPackage(t *testing.T, name, version string, requires ...Requirement) Package {
+	p := Package{
+		BasePackage: BasePackage{
+			Name:    name,
+			Version: version,
+		},
+		Requires:      requires,
+		versionSemVer: semver.MustParse(version),
+	}
+	require.NoError(t, p.loadRequirements())
+	return p
+}
+
+func TestResolveRequirements(t *testing.T) {
+	system100 := newRequirementTestPackage(t, "system", "1.0.0")
+	system120 := newRequirementTestPackage(t, "system", "1.2.0")
+	system200 := newRequirementTestPackage(t, "system", "2.0.0")
+	endpoint := newRequirementTestPackage(t, "endpoint", "1.0.0", Requirement{Name: "system", Version: "~1.0.0"})
+
+	tests := []struct {
+		description string
+		root        Package
+		expected    []string
+		err         string
+	}{
+		{
+			"no requirements",
+			system100,
+			[]string{"system@1.0.0"},
+			"",
+		},
+		{
+			"newest matching",
+			newRequirementTestPackage(t, "nginx", "1.0.0", Requirement{Name: "system", Version: "^1.0.0"}),
+			[]string{"system@1.2.0", "nginx@1.0.0"},
+			"",
+		},
+		{
+			"transitive requirements",
+			newRequirementTestPackage(t, "nginx", "1.0.0", Requirement{Name: "endpoint", Version: ">=1.0.0"}),
+			[]string{"system@1.0.0", "endpoint@1.0.0", "nginx@1.0.0"},
+			"",
+		},
+		{
+			"missing requirement",
+			newRequirementTestPackage(t, "nginx", "1.0.0", Requirement{Name: "missing", Version: "^1.0.0"}),
+			nil,
+			"missing required package missing (required by: nginx@1.0.0 requires ^1.0.0)",
+		},
+		{
+			"conflicting requirements",
+			newRequirementTestPackage(t, "nginx", "1.0.0",
+				Requirement{Name: "endpoint", Version: "^1.0.0"},
+				Requirement{Name: "system", Version: "^2.0.0"},
+			),
+			nil,
+			"conflicting requirements for package system: nginx@1.0.0 requires ^2.0.0, endpoint@1.0.0 requires ~1.0.0",
+		},
+	}
+
+	packages := Packages{system100, system120, system200, endpoint}
+	runResolveRequirementsTests(t, packages, tests)
+}
+
+func TestResolveRequirementsDowngrade(t *testing.T) {
+	// The newest version of aws requires system 2.x, but the required version of endpoint only works
+	// with aws 1.0.x, which requires system 1.x
+	packages := Packages{
+		newRequirementTestPackage(t, "system", "1.0.0"),
+		newRequirementTestPackage(t, "system", "2.0.0"),
+		newRequirementTestPackage(t, "aws", "1.0.0", Requirement{Name: "system", Version: "^1.0.0"}),
+		newRequirementTestPackage(t, "aws", "1.1.0", Requirement{Name: "system", Version: "^2.0.0"}),
+		newRequirementTestPackage(t, "endpoint", "1.0.0", Requirement{Name: "aws", Version: "~1.0.0"}),
+	}
+
+	tests := []struct {
+		description string
+		root        Package
+		expected    []string
+		err         string
+	}{
+		{
+			"newest without downgrade",
+			newRequirementTestPackage(t, "nginx", "1.0.0", Requirement{Name: "aws", Version: "^1.0.0"}),
+			[]string{"system@2.0.0", "aws@1.1.0", "nginx@1.0.0"},
+			"",
+		},
+		{
+			"downgrade drops requirements of replaced version",
+			newRequirementTestPackage(t, "nginx", "1.0.0",
+				Requirement{Name: "aws", Version: "^1.0.0"},
+				Requirement{Name: "endpoint", Version: "^1.0.0"},
+			),
+			[]string{"system@1.0.0", "aws@1.0.0", "endpoint@1.0.0", "nginx@1.0.0"},
+			"",
+		},
+		{
+			"downgrade conflicting with root requirement",
+			newRequirementTestPackage(t, "nginx", "1.0.0",
+				Requirement{Name: "aws", Version: "^1.0.0"},
+				Requirement{Name: "endpoint", Version: "^1.0.0"},
+				Requirement{Name: "system", Version: "^2.0.0"},
+			),
+			nil,
+			"conflicting requirements for package system: nginx@1.0.0 requires ^2.0.0, aws@1.0.0 requires ^1.0.0",
+		},
+	}
+	runResolveRequirementsTests(t, packages, tests)
+}
+
+func runResolveRequirementsTests(t *testing.T, packages Packages, tests []struct {
+	description string
+	root        Package
+	expected    []string
+	err         string
+}) {
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			resolved, err := packages.ResolveRequirements(tt.root, nil)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+
+			var names []string
+			for _, p := range resolved {
+				names = append(names, p.Name+"@"+p.Version)
+			}
+			assert.Equal(t, tt.expected, names)
+		})
+	}
+}
+
+func TestLoadRequirementsInvalid(t *testing.T) {
+	p := Package{
+		BasePackage: BasePackage{Name: "nginx"},
+		Requires:    []Requirement{{Name: "system", Version: "foo"}},
+	}
+	assert.Error(t, p.loadRequirements())
+
+	p.Requires = []Requirement{{Name: "nginx", Version: "^1.0.0"}}
+	assert.Error(t, p.loadRequirements())
+}
@@ -0,0 +1,405 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aymerick/raymond"
+	"github.com/aymerick/raymond/ast"
+	"github.com/aymerick/raymond/parser"
+	"github.com/joeshaw/multierror"
+	"github.com/pkg/errors"
+	yamlv2 "gopkg.in/yaml.v2"
+)
+
+const (
+	DirAgentStream            = "agent/stream"
+	DirAgentInput             = "agent/input"
+	DefaultStreamTemplatePath = "stream.yml.hbs"
+)
+
+// templateBuiltinHelpers are the helpers provided by Handlebars itself.
+var templateBuiltinHelpers = map[string]bool{
+	"if":     true,
+	"unless": true,
+	"each":   true,
+	"with":   true,
+	"log":    true,
+	"lookup": true,
+	"equal":  true,
+}
+
+// streamTemplateHelpers are the Handlebars helpers available in stream templates, same as in Fleet.
+var streamTemplateHelpers = map[string]interface{}{
+	"contains":      containsHelper,
+	"escape_string": escapeStringHelper,
+	"to_json":       toJSONHelper,
+}
+
+// TemplateError is returned if a stream template cannot be rendered, or the rendered output is not valid YAML.
+type TemplateError struct {
+	Path string
+	Err  error
+}
+
+func (e *TemplateError) Error() string {
+	return fmt.Sprintf("template %s: %v", e.Path, e.Err)
+}
+
+// GetStream returns the stream of the data stream for the given input, nil if it doesn't exist.
+func (d *DataStream) GetStream(input string) *Stream {
+	for i := range d.Streams {
+		if d.Streams[i].Input == input {
+			return &d.Streams[i]
+		}
+	}
+	return nil
+}
+
+// StreamVariables returns the variables available in the template of a stream. These are the package variables,
+// the variables of the policy template inputs of the same type and the stream variables. If variables with the
+// same name are defined on multiple levels, the most specific one wins.
+func (p *Package) StreamVariables(d *DataStream, s *Stream) []Variable {
+	variables := map[string]Variable{}
+	for _, v := range p.Vars {
+		variables[v.Name] = v
+	}
+	for _, t := range p.PolicyTemplates {
+		if !t.appliesTo(d) {
+			continue
+		}
+		for _, i := range t.Inputs {
+			if i.Type != s.Input {
+				continue
+			}
+			for _, v := range i.Vars {
+				variables[v.Name] = v
+			}
+		}
+	}
+	for _, v := range s.Vars {
+		variables[v.Name] = v
+	}
+
+	var names []string
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := make([]Variable, 0, len(names))
+	for _, name := range names {
+		result = append(result, variables[name])
+	}
+	return result
+}
+
+// appliesTo checks if the policy template applies to the data stream. Policy templates without
+// explicit data streams apply to all of them.
+func (t *PolicyTemplate) appliesTo(d *DataStream) bool {
+	if len(t.DataStreams) == 0 {
+		return true
+	}
+	for _, path := range t.DataStreams {
+		if path == d.Path {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveStreamVariables applies the defaults of the stream variables to the given values. Values for unknown
+// variables and missing values for required variables are reported as errors.
+func (p *Package) ResolveStreamVariables(d *DataStream, s *Stream, values map[string]interface{}) (map[string]interface{}, error) {
+	variables := p.StreamVariables(d, s)
+
+	known := map[string]bool{}
+	resolved := map[string]interface{}{}
+	var errs multierror.Errors
+	for _, v := range variables {
+		known[v.Name] = true
+
+		value, ok := values[v.Name]
+		if !ok || value == nil {
+			value = v.Default
+		}
+		if value == nil {
+			if v.Required {
+				errs = append(errs, fmt.Errorf("missing value for required variable \"%s\"", v.Name))
+			}
+			continue
+		}
+		resolved[v.Name] = value
+	}
+
+	var unknown []string
+	for name := range values {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		errs = append(errs, fmt.Errorf("unknown variable \"%s\"", name))
+	}
+	return resolved, errs.Err()
+}
+
+// RenderStreamTemplate renders the Handlebars template of a stream with the given variables and checks
+// that the result is valid YAML.
+func (d *DataStream) RenderStreamTemplate(s *Stream, vars map[string]interface{}) ([]byte, error) {
+	templatePath := filepath.Join(d.BasePath, DirAgentStream, s.TemplatePath)
+	relativePath := filepath.ToSlash(filepath.Join(DirAgentStream, s.TemplatePath))
+
+	source, err := ioutil.ReadFile(templatePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "reading stream template failed (path: %s)", templatePath)
+	}
+
+	tpl, err := raymond.Parse(string(source))
+	if err != nil {
+		return nil, &TemplateError{Path: relativePath, Err: err}
+	}
+	tpl.RegisterHelpers(streamTemplateHelpers)
+
+	rendered, err := tpl.Exec(safeTemplateValue(vars))
+	if err != nil {
+		return nil, &TemplateError{Path: relativePath, Err: err}
+	}
+
+	var output interface{}
+	err = yamlv2.Unmarshal([]byte(rendered), &output)
+	if err != nil {
+		return nil, &TemplateError{Path: relativePath, Err: errors.Wrap(err, "rendered stream is not valid YAML")}
+	}
+	return []byte(rendered), nil
+}
+
+// validateStreamTemplates checks that the templates of all streams of the data stream exist, can be parsed
+// and only reference declared variables.
+func (p *Package) validateStreamTemplates(d *DataStream) error {
+	var errs multierror.Errors
+	for i := range d.Streams {
+		s := &d.Streams[i]
+		templatePath := filepath.Join(d.BasePath, DirAgentStream, s.TemplatePath)
+		relativePath := filepath.ToSlash(filepath.Join(DirAgentStream, s.TemplatePath))
+
+		err := validateTemplateFile(templatePath, relativePath, p.StreamVariables(d, s))
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "invalid template of stream (input: %s)", s.Input))
+		}
+	}
+	return errs.Err()
+}
+
+// validateInputTemplates checks the templates of the policy template inputs defining one.
+func (p *Package) validateInputTemplates() error {
+	var errs multierror.Errors
+	for _, t := range p.PolicyTemplates {
+		for _, i := range t.Inputs {
+			if i.TemplatePath == "" {
+				continue
+			}
+
+			templatePath := filepath.Join(p.BasePath, DirAgentInput, i.TemplatePath)
+			relativePath := filepath.ToSlash(filepath.Join(DirAgentInput, i.TemplatePath))
+
+			variables := append(append([]Variable{}, p.Vars...), i.Vars...)
+			err := validateTemplateFile(templatePath, relativePath, variables)
+			if err != nil {
+				errs = append(errs, errors.Wrapf(err, "invalid template of input (policy template: %s, input: %s)", t.Name, i.Type))
+			}
+		}
+	}
+	return errs.Err()
+}
+
+func validateTemplateFile(templatePath, relativePath string, variables []Variable) error {
+	source, err := ioutil.ReadFile(templatePath)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("template %s does not exist", relativePath)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "reading template failed (path: %s)", templatePath)
+	}
+
+	program, err := parser.Parse(string(source))
+	if err != nil {
+		return &TemplateError{Path: relativePath, Err: err}
+	}
+
+	declared := map[string]bool{}
+	for _, v := range variables {
+		declared[v.Name] = true
+	}
+
+	var errs multierror.Errors
+	for _, ref := range templateReferences(program) {
+		if declared[ref.name] || declared[ref.path] {
+			continue
+		}
+		errs = append(errs, &TemplateError{
+			Path: relativePath,
+			Err:  fmt.Errorf("line %d: variable \"%s\" is not declared", ref.line, ref.path),
+		})
+	}
+	return errs.Err()
+}
+
+// templateReference is a variable referenced in a template.
+type templateReference struct {
+	// name is the first level of the path, path the full path as written in the template
+	name string
+	path string
+	line int
+}
+
+// templateReferences returns the variables referenced in the root context of a template. Paths inside
+// `each` and `with` blocks are relative to another context and are ignored.
+func templateReferences(program *ast.Program) []templateReference {
+	var refs []templateReference
+	collectProgramReferences(program, &refs)
+	return refs
+}
+
+func collectProgramReferences(program *ast.Program, refs *[]templateReference) {
+	if program == nil {
+		return
+	}
+
+	for _, node := range program.Body {
+		switch n := node.(type) {
+		case *ast.MustacheStatement:
+			collectExpressionReferences(n.Expression, refs)
+		case *ast.BlockStatement:
+			collectExpressionReferences(n.Expression, refs)
+
+			helper := templateHelperName(n.Expression)
+			if helper != "" && helper != "each" && helper != "with" {
+				collectProgramReferences(n.Program, refs)
+			}
+			collectProgramReferences(n.Inverse, refs)
+		}
+	}
+}
+
+func collectExpressionReferences(expression *ast.Expression, refs *[]templateReference) {
+	if templateHelperName(expression) == "" {
+		if path, ok := expression.Path.(*ast.PathExpression); ok {
+			addTemplateReference(path, refs)
+		}
+	}
+
+	for _, param := range expression.Params {
+		collectNodeReferences(param, refs)
+	}
+	if expression.Hash != nil {
+		for _, pair := range expression.Hash.Pairs {
+			collectNodeReferences(pair.Val, refs)
+		}
+	}
+}
+
+func collectNodeReferences(node ast.Node, refs *[]templateReference) {
+	switch n := node.(type) {
+	case *ast.PathExpression:
+		addTemplateReference(n, refs)
+	case *ast.SubExpression:
+		collectExpressionReferences(n.Expression, refs)
+	case *ast.Expression:
+		collectExpressionReferences(n, refs)
+	}
+}
+
+// templateHelperName returns the name of the helper called by the expression, empty if the expression
+// is a plain variable.
+func templateHelperName(expression *ast.Expression) string {
+	path, ok := expression.Path.(*ast.PathExpression)
+	if !ok {
+		return ""
+	}
+
+	_, custom := streamTemplateHelpers[path.Original]
+	if len(expression.Params) > 0 || expression.Hash != nil || templateBuiltinHelpers[path.Original] || custom {
+		return path.Original
+	}
+	return ""
+}
+
+func addTemplateReference(path *ast.PathExpression, refs *[]templateReference) {
+	// Data variables like @index, `this` and paths relative to other contexts are not variables of the stream
+	if path.Data || path.Scoped || len(path.Parts) == 0 || strings.HasPrefix(path.Original, "..") {
+		return
+	}
+
+	*refs = append(*refs, templateReference{
+		name: path.Parts[0],
+		path: strings.Join(path.Parts, "."),
+		line: path.Line,
+	})
+}
+
+// safeTemplateValue marks all strings as safe, so they are not HTML escaped by the template engine.
+func safeTemplateValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case string:
+		return raymond.SafeString(value)
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(value))
+		for k, child := range value {
+			m[k] = safeTemplateValue(child)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(value))
+		for i, child := range value {
+			s[i] = safeTemplateValue(child)
+		}
+		return s
+	default:
+		return v
+	}
+}
+
+// containsHelper renders the block if the value is in the list, or is a substring of it.
+func containsHelper(value, list interface{}, options *raymond.Options) raymond.SafeString {
+	var found bool
+	switch l := list.(type) {
+	case []interface{}:
+		for _, item := range l {
+			if raymond.Str(item) == raymond.Str(value) {
+				found = true
+				break
+			}
+		}
+	default:
+		found = strings.Contains(raymond.Str(list), raymond.Str(value))
+	}
+
+	if found {
+		return raymond.SafeString(options.Fn())
+	}
+	return raymond.SafeString(options.Inverse())
+}
+
+// escapeStringHelper quotes a string so it can be used as a single-quoted YAML string.
+func escapeStringHelper(value interface{}) raymond.SafeString {
+	return raymond.SafeString("'" + strings.Replace(raymond.Str(value), "'", "''", -1) + "'")
+}
+
+func toJSONHelper(value interface{}) raymond.SafeString {
+	body, err := json.Marshal(value)
+	if err != nil {
+		panic(errors.Wrap(err, "encoding value to JSON failed"))
+	}
+	return raymond.SafeString(body)
+}
@@ -0,0 +1,83 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package util
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/joeshaw/multierror"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderStreamTemplate(t *testing.T) {
+	tests := []struct {
+		title    string
+		basePath string
+		vars     map[string]interface{}
+		expected string
+		err      string
+	}{
+		{
+			title:    "multi values and helpers",
+			basePath: "../testdata/templates/render/multi-values-and-helpers",
+			vars: map[string]interface{}{
+				"paths":   []interface{}{"/var/log/<app>.log"},
+				"formats": []interface{}{"json", "plain"},
+				"tags":    []interface{}{"a", "b"},
+				"query":   "it's",
+			},
+			expected: `paths:
+  - /var/log/<app>.log
+json.keys_under_root: true
+tags: ["a","b"]
+query: 'it''s'
+`,
+		},
+		{
+			title:    "parse error",
+			basePath: "../testdata/templates/render/parse-error",
+			err:      "template agent/stream/stream.yml.hbs: Parse error on line 4",
+		},
+		{
+			title:    "invalid yaml",
+			basePath: "../testdata/templates/render/invalid-yaml",
+			vars:     map[string]interface{}{"foo": "bar"},
+			err:      "template agent/stream/stream.yml.hbs: rendered stream is not valid YAML: yaml: line 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.title, func(t *testing.T) {
+			d := DataStream{BasePath: tt.basePath}
+			rendered, err := d.RenderStreamTemplate(&Stream{TemplatePath: DefaultStreamTemplatePath}, tt.vars)
+			if tt.err != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, string(rendered))
+		})
+	}
+}
+
+func TestValidateTemplateFile(t *testing.T) {
+	basePath := "../testdata/templates/validate"
+	templatePath := filepath.Join(basePath, DefaultStreamTemplatePath)
+
+	variables := []Variable{{Name: "hosts"}, {Name: "ssl.enabled"}, {Name: "formats"}, {Name: "period"}}
+	err := validateTemplateFile(templatePath, DefaultStreamTemplatePath, variables)
+	require.Error(t, err)
+	assert.Equal(t, []error{
+		&TemplateError{Path: DefaultStreamTemplatePath, Err: errors.New(`line 6: variable "ssl" is not declared`)},
+		&TemplateError{Path: DefaultStreamTemplatePath, Err: errors.New(`line 11: variable "timeout" is not declared`)},
+	}, []error(err.(*multierror.MultiError).Errors))
+
+	err = validateTemplateFile(filepath.Join(basePath, "missing.yml.hbs"), "missing.yml.hbs", variables)
+	assert.EqualError(t, err, "template missing.yml.hbs does not exist")
+}
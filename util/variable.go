@@ -0,0 +1,166 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package util
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joeshaw/multierror"
+	"github.com/pkg/errors"
+	yamlv2 "gopkg.in/yaml.v2"
+)
+
+const (
+	VariableTypeText     = "text"
+	VariableTypeTextarea = "textarea"
+	VariableTypePassword = "password"
+	VariableTypeBool     = "bool"
+	VariableTypeInteger  = "integer"
+	VariableTypeYAML     = "yaml"
+	VariableTypeDuration = "duration"
+)
+
+var variableTypes = map[string]bool{
+	VariableTypeText:     true,
+	VariableTypeTextarea: true,
+	VariableTypePassword: true,
+	VariableTypeBool:     true,
+	VariableTypeInteger:  true,
+	VariableTypeYAML:     true,
+	VariableTypeDuration: true,
+}
+
+// validateDefinition checks that the type of the variable is known and that the default matches it.
+func (v *Variable) validateDefinition() error {
+	if !variableTypes[v.Type] {
+		return fmt.Errorf("variable \"%s\" has invalid type \"%s\"", v.Name, v.Type)
+	}
+
+	if v.Default == nil {
+		return nil
+	}
+
+	if v.Type == VariableTypePassword {
+		return fmt.Errorf("variable \"%s\" of type password must not have a default", v.Name)
+	}
+
+	err := v.ValidateValue(v.Default)
+	if err != nil {
+		return errors.Wrapf(err, "invalid default of variable \"%s\"", v.Name)
+	}
+	return nil
+}
+
+// ValidateValue checks that the value matches the type of the variable. Values of multi variables
+// must be lists of values of the type.
+func (v *Variable) ValidateValue(value interface{}) error {
+	if !v.Multi {
+		return validateVariableValue(v.Type, value)
+	}
+
+	values, ok := value.([]interface{})
+	if !ok {
+		return fmt.Errorf("expected list of %s values but got %s", v.Type, valueTypeName(value))
+	}
+	for i, value := range values {
+		err := validateVariableValue(v.Type, value)
+		if err != nil {
+			return errors.Wrapf(err, "invalid value at index %d", i)
+		}
+	}
+	return nil
+}
+
+func validateVariableValue(varType string, value interface{}) error {
+	switch varType {
+	case VariableTypeText, VariableTypeTextarea, VariableTypePassword:
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("expected string but got %s", valueTypeName(value))
+		}
+	case VariableTypeBool:
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("expected boolean but got %s", valueTypeName(value))
+		}
+	case VariableTypeInteger:
+		if !isInteger(value) {
+			return fmt.Errorf("expected integer but got %s", valueTypeName(value))
+		}
+	case VariableTypeYAML:
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("expected YAML string but got %s", valueTypeName(value))
+		}
+		var v interface{}
+		err := yamlv2.Unmarshal([]byte(s), &v)
+		if err != nil {
+			return errors.Wrap(err, "invalid YAML")
+		}
+	case VariableTypeDuration:
+		s, ok := value.(string)
+		if !ok || !isDuration(s) {
+			return fmt.Errorf("expected duration like 10s, 5m or 2h but got %v", value)
+		}
+	default:
+		return fmt.Errorf("unknown variable type \"%s\"", varType)
+	}
+	return nil
+}
+
+// isDuration returns true for durations in the format of Go, e.g. `1h30m`, or in days, e.g. `7d`.
+func isDuration(value string) bool {
+	if days := strings.TrimSuffix(value, "d"); days != value {
+		_, err := strconv.ParseUint(days, 10, 64)
+		return err == nil
+	}
+	_, err := time.ParseDuration(value)
+	return err == nil
+}
+
+func isInteger(value interface{}) bool {
+	switch v := value.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return true
+	case float64:
+		// Numbers decoded from JSON are always floats
+		return v == math.Trunc(v)
+	default:
+		return false
+	}
+}
+
+func valueTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case []interface{}:
+		return "list"
+	case map[string]interface{}:
+		return "object"
+	}
+	if isInteger(value) {
+		return "integer"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// validateVariableDefinitions validates the definitions of all the given variables.
+func validateVariableDefinitions(variables []Variable) error {
+	var errs multierror.Errors
+	for i := range variables {
+		err := variables[i].validateDefinition()
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs.Err()
+}
@@ -0,0 +1,56 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVariableValidateDefinition(t *testing.T) {
+	tests := []struct {
+		variable Variable
+		err      string
+	}{
+		{Variable{Name: "paths", Type: "text", Multi: true, Default: []interface{}{"/var/log/*.log"}}, ""},
+		{Variable{Name: "paths", Type: "text", Multi: true, Default: "/var/log/*.log"}, `invalid default of variable "paths": expected list of text values but got string`},
+		{Variable{Name: "enabled", Type: "bool", Default: true}, ""},
+		{Variable{Name: "enabled", Type: "bool", Default: "true"}, `invalid default of variable "enabled": expected boolean but got string`},
+		{Variable{Name: "workers", Type: "integer", Default: uint64(2)}, ""},
+		{Variable{Name: "workers", Type: "integer", Default: 2.5}, `invalid default of variable "workers": expected integer but got float64`},
+		{Variable{Name: "processors", Type: "yaml", Default: "- add_host_metadata: ~\n"}, ""},
+		{Variable{Name: "processors", Type: "yaml", Default: "foo: [bar"}, `invalid default of variable "processors": invalid YAML: yaml: line 1: did not find expected ',' or ']'`},
+		{Variable{Name: "period", Type: "duration", Default: "10s"}, ""},
+		{Variable{Name: "period", Type: "duration", Default: "1h30m"}, ""},
+		{Variable{Name: "period", Type: "duration", Default: "1.5s"}, ""},
+		{Variable{Name: "period", Type: "duration", Default: "500µs"}, ""},
+		{Variable{Name: "period", Type: "duration", Default: "7d"}, ""},
+		{Variable{Name: "period", Type: "duration", Default: "1.5d"}, `invalid default of variable "period": expected duration like 10s, 5m or 2h but got 1.5d`},
+		{Variable{Name: "period", Type: "duration", Default: "ten seconds"}, `invalid default of variable "period": expected duration like 10s, 5m or 2h but got ten seconds`},
+		{Variable{Name: "password", Type: "password"}, ""},
+		{Variable{Name: "password", Type: "password", Default: "changeme"}, `variable "password" of type password must not have a default`},
+		{Variable{Name: "hosts", Type: "url"}, `variable "hosts" has invalid type "url"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.variable.Name+" "+tt.variable.Type, func(t *testing.T) {
+			err := tt.variable.validateDefinition()
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestVariableValidateValue(t *testing.T) {
+	workers := Variable{Name: "workers", Type: "integer", Multi: true}
+
+	// Numbers decoded from JSON are floats
+	assert.NoError(t, workers.ValidateValue([]interface{}{float64(1), float64(2)}))
+	assert.EqualError(t, workers.ValidateValue([]interface{}{float64(1), "2"}), "invalid value at index 1: expected integer but got string")
+}